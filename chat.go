@@ -0,0 +1,121 @@
+package twitchdl
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jybp/twitch-downloader/twitch"
+	"github.com/pkg/errors"
+)
+
+// Chat retrieves the chat replay of the VOD "vodID" between start and end
+// using the provided http.Client.
+// An end of 0 retrieves the chat until the end of the VOD.
+func Chat(ctx context.Context, client *http.Client, clientID, vodID string, start, end time.Duration, opts ...Option) ([]twitch.Comment, error) {
+	if start < 0 || end < 0 {
+		return nil, errors.New("Negative timestamps are not allowed")
+	}
+	if start >= end && end != time.Duration(0) {
+		return nil, errors.New("End timestamp is not after Start timestamp")
+	}
+	api := newAPI(client, clientID, newOptions(opts))
+	var comments []twitch.Comment
+	seen := map[string]bool{}
+	cursor := ""
+	for {
+		page, next, err := api.Comments(ctx, vodID, start, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range page {
+			if end != 0 && comment.Offset >= end {
+				return comments, nil
+			}
+			if comment.Offset < start || seen[comment.ID] {
+				continue
+			}
+			seen[comment.ID] = true
+			comments = append(comments, comment)
+		}
+		if len(next) == 0 || next == cursor {
+			return comments, nil
+		}
+		cursor = next
+	}
+}
+
+// chatLine is a comment encoded by WriteChat.
+type chatLine struct {
+	ID        string         `json:"id"`
+	Timestamp float64        `json:"timestamp"`
+	CreatedAt time.Time      `json:"created_at"`
+	User      chatUser       `json:"user"`
+	Badges    []chatBadge    `json:"badges,omitempty"`
+	Emotes    []chatEmote    `json:"emotes,omitempty"`
+	Fragments []chatFragment `json:"fragments"`
+	Message   string         `json:"message"`
+}
+
+type chatUser struct {
+	ID          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+	Color       string `json:"color,omitempty"`
+}
+
+type chatBadge struct {
+	SetID   string `json:"set_id"`
+	Version string `json:"version"`
+}
+
+// chatEmote locates an emote inside the message. Begin and End are rune indexes.
+type chatEmote struct {
+	ID    string `json:"id"`
+	Begin int    `json:"begin"`
+	End   int    `json:"end"`
+}
+
+type chatFragment struct {
+	Text    string `json:"text"`
+	EmoteID string `json:"emote_id,omitempty"`
+}
+
+// WriteChat writes comments to w as JSON lines.
+// The timestamp of a comment is its offset inside the VOD in seconds.
+func WriteChat(w io.Writer, comments []twitch.Comment) error {
+	enc := json.NewEncoder(w)
+	for _, c := range comments {
+		line := chatLine{
+			ID:        c.ID,
+			Timestamp: c.Offset.Seconds(),
+			CreatedAt: c.CreatedAt,
+			User: chatUser{
+				ID:          c.UserID,
+				Login:       c.UserLogin,
+				DisplayName: c.UserName,
+				Color:       c.UserColor,
+			},
+			Fragments: []chatFragment{},
+			Message:   c.Message(),
+		}
+		for _, b := range c.Badges {
+			line.Badges = append(line.Badges, chatBadge{SetID: b.SetID, Version: b.Version})
+		}
+		var position int
+		for _, f := range c.Fragments {
+			length := len([]rune(f.Text))
+			if len(f.EmoteID) > 0 {
+				line.Emotes = append(line.Emotes, chatEmote{ID: f.EmoteID, Begin: position, End: position + length})
+			}
+			line.Fragments = append(line.Fragments, chatFragment{Text: f.Text, EmoteID: f.EmoteID})
+			position += length
+		}
+		if err := enc.Encode(line); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package chat renders the chat replay of a VOD as subtitles.
+package chat
+
+import (
+	"bufio"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jybp/twitch-downloader/twitch"
+	"github.com/pkg/errors"
+)
+
+// Options configures the rendering of the chat replay.
+type Options struct {
+	// Start is the position of the video inside the VOD. Comments are shifted
+	// by Start so that they match a video downloaded with the same start.
+	// Comments before Start are dropped.
+	Start time.Duration
+	// Duration is the time a comment stays on screen. Defaults to 5s.
+	Duration time.Duration
+	// Lines is the maximum number of comments displayed at once. Defaults to 6.
+	Lines int
+	// Width and Height are the resolution of the video. Only used by ASS.
+	// Default to 1920x1080.
+	Width  int
+	Height int
+	// FontSize is the font size used by ASS. Defaults to Height/24.
+	FontSize int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Duration <= 0 {
+		o.Duration = 5 * time.Second
+	}
+	if o.Lines <= 0 {
+		o.Lines = 6
+	}
+	if o.Width <= 0 || o.Height <= 0 {
+		o.Width, o.Height = 1920, 1080
+	}
+	if o.FontSize <= 0 {
+		o.FontSize = o.Height / 24
+	}
+	return o
+}
+
+// cue is a comment rebased on Options.Start.
+type cue struct {
+	start   time.Duration
+	user    string
+	color   string
+	message string
+}
+
+func cues(comments []twitch.Comment, o Options) []cue {
+	var list []cue
+	for _, c := range comments {
+		if c.Offset < o.Start {
+			continue
+		}
+		user := c.UserName
+		if len(user) == 0 {
+			user = c.UserLogin
+		}
+		list = append(list, cue{
+			start:   c.Offset - o.Start,
+			user:    user,
+			color:   color(c),
+			message: strings.Replace(c.Message(), "\n", " ", -1),
+		})
+	}
+	return list
+}
+
+// defaultColors are the colors given by twitch to users without a custom color.
+var defaultColors = []string{
+	"#FF0000", "#0000FF", "#008000", "#B22222", "#FF7F50",
+	"#9ACD32", "#FF4500", "#2E8B57", "#DAA520", "#D2691E",
+	"#5F9EA0", "#1E90FF", "#FF69B4", "#8A2BE2", "#00FF7F",
+}
+
+// color returns the color of the user name as "#RRGGBB".
+func color(c twitch.Comment) string {
+	if len(c.UserColor) == 7 && c.UserColor[0] == '#' {
+		return strings.ToUpper(c.UserColor)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(c.UserLogin))
+	return defaultColors[h.Sum32()%uint32(len(defaultColors))]
+}
+
+// window is the text displayed between start and end.
+type window struct {
+	start, end time.Duration
+	cues       []cue
+}
+
+// windows groups the cues into consecutive windows displaying the last o.Lines
+// cues still on screen, for formats that cannot scroll.
+func windows(list []cue, o Options) []window {
+	var ws []window
+	for i, c := range list {
+		end := c.start + o.Duration
+		if i+1 < len(list) && list[i+1].start < end {
+			end = list[i+1].start
+		}
+		if end <= c.start {
+			continue
+		}
+		first := i
+		for first > 0 && i-first+1 < o.Lines && list[first-1].start+o.Duration > c.start {
+			first--
+		}
+		ws = append(ws, window{start: c.start, end: end, cues: list[first : i+1]})
+	}
+	return ws
+}
+
+// SRT writes the comments as SubRip subtitles.
+func SRT(w io.Writer, comments []twitch.Comment, o Options) error {
+	o = o.withDefaults()
+	bw := bufio.NewWriter(w)
+	for i, win := range windows(cues(comments, o), o) {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n", i+1, timestamp(win.start, ","), timestamp(win.end, ","))
+		for _, c := range win.cues {
+			fmt.Fprintf(bw, "<font color=\"%s\">%s</font>: %s\n", c.color, markupEscape(c.user), markupEscape(c.message))
+		}
+		fmt.Fprintln(bw)
+	}
+	return errors.WithStack(bw.Flush())
+}
+
+// WebVTT writes the comments as WebVTT subtitles.
+func WebVTT(w io.Writer, comments []twitch.Comment, o Options) error {
+	o = o.withDefaults()
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, "WEBVTT\n\n")
+	for _, win := range windows(cues(comments, o), o) {
+		fmt.Fprintf(bw, "%s --> %s line:0 align:start\n", timestamp(win.start, "."), timestamp(win.end, "."))
+		for _, c := range win.cues {
+			fmt.Fprintf(bw, "<v %s>%s\n", markupEscape(c.user), markupEscape(c.message))
+		}
+		fmt.Fprintln(bw)
+	}
+	return errors.WithStack(bw.Flush())
+}
+
+// ASS writes the comments as Advanced SubStation Alpha subtitles.
+// Comments scroll from right to left across the top of the video.
+func ASS(w io.Writer, comments []twitch.Comment, o Options) error {
+	o = o.withDefaults()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "[Script Info]\nScriptType: v4.00+\nPlayResX: %d\nPlayResY: %d\nWrapStyle: 2\n\n", o.Width, o.Height)
+	fmt.Fprint(bw, "[V4+ Styles]\n")
+	fmt.Fprint(bw, "Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(bw, "Style: Chat,Arial,%d,&H00FFFFFF,&H00FFFFFF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,2,0,7,0,0,0,1\n\n", o.FontSize)
+	fmt.Fprint(bw, "[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+
+	// lanes holds, for each line, the time at which its last comment
+	// has entirely entered the screen.
+	lanes := make([]time.Duration, o.Lines)
+	for _, c := range cues(comments, o) {
+		text := c.user + ": " + c.message
+		width := len([]rune(text)) * o.FontSize * 3 / 5
+		speed := float64(o.Width+width) / o.Duration.Seconds()
+		free := c.start + time.Duration(float64(width)/speed*float64(time.Second))
+
+		lane := 0
+		for i := range lanes {
+			if lanes[i] <= c.start {
+				lane = i
+				break
+			}
+			if lanes[i] < lanes[lane] {
+				lane = i
+			}
+		}
+		lanes[lane] = free
+
+		y := lane * o.FontSize * 6 / 5
+		fmt.Fprintf(bw, "Dialogue: 0,%s,%s,Chat,,0,0,0,,{\\move(%d,%d,%d,%d)}{\\c%s}%s{\\c&HFFFFFF&}: %s\n",
+			assTimestamp(c.start), assTimestamp(c.start+o.Duration),
+			o.Width, y, -width, y,
+			assColor(c.color), assEscape(c.user), assEscape(c.message))
+	}
+	return errors.WithStack(bw.Flush())
+}
+
+// timestamp formats d as hh:mm:ss followed by sep and milliseconds.
+func timestamp(d time.Duration, sep string) string {
+	ms := int64(d / time.Millisecond)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// assTimestamp formats d as h:mm:ss.cc.
+func assTimestamp(d time.Duration) string {
+	cs := int64(d / (10 * time.Millisecond))
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+// assColor converts "#RRGGBB" to the ASS "&HBBGGRR&" notation.
+func assColor(c string) string {
+	return "&H" + c[5:7] + c[3:5] + c[1:3] + "&"
+}
+
+// assEscape prevents s from being interpreted as ASS override tags or escapes
+// such as \N. Braces are replaced by their full-width forms and a word joiner
+// follows each backslash, since ASS has no escape sequence for them.
+func assEscape(s string) string {
+	return strings.NewReplacer(`\`, "\\\u2060", "{", "\uFF5B", "}", "\uFF5D").Replace(s)
+}
+
+// markupEscape escapes the characters of the tags of SRT and WebVTT.
+func markupEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
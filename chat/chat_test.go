@@ -0,0 +1,73 @@
+package chat_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jybp/twitch-downloader/chat"
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+var comments = []twitch.Comment{
+	{Offset: 5 * time.Second, UserLogin: "early", UserName: "Early", Fragments: []twitch.Fragment{{Text: "dropped"}}},
+	{Offset: 12 * time.Second, UserLogin: "user", UserName: "User", UserColor: "#ff8000", Fragments: []twitch.Fragment{{Text: "hello "}, {Text: "Kappa", EmoteID: "25"}}},
+	{Offset: 12*time.Second + 500*time.Millisecond, UserLogin: "other", UserName: "Other", UserColor: "#0000FF", Fragments: []twitch.Fragment{{Text: "<hi>"}}},
+}
+
+func TestSRT(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, chat.SRT(&buf, comments, chat.Options{Start: 10 * time.Second}))
+	assert.Equal(t, `1
+00:00:02,000 --> 00:00:02,500
+<font color="#FF8000">User</font>: hello Kappa
+
+2
+00:00:02,500 --> 00:00:07,500
+<font color="#FF8000">User</font>: hello Kappa
+<font color="#0000FF">Other</font>: &lt;hi&gt;
+
+`, buf.String())
+}
+
+func TestWebVTT(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, chat.WebVTT(&buf, comments, chat.Options{Start: 10 * time.Second, Lines: 1}))
+	assert.Equal(t, `WEBVTT
+
+00:00:02.000 --> 00:00:02.500 line:0 align:start
+<v User>hello Kappa
+
+00:00:02.500 --> 00:00:07.500 line:0 align:start
+<v Other>&lt;hi&gt;
+
+`, buf.String())
+}
+
+func TestASS(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, chat.ASS(&buf, comments, chat.Options{Start: 10 * time.Second}))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "[Script Info]\n"))
+	var dialogues []string
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "Dialogue:") {
+			dialogues = append(dialogues, line)
+		}
+	}
+	require.Len(t, dialogues, 2)
+	assert.Equal(t, `Dialogue: 0,0:00:02.00,0:00:07.00,Chat,,0,0,0,,{\move(1920,0,-459,0)}{\c&H0080FF&}User{\c&HFFFFFF&}: hello Kappa`, dialogues[0])
+	// The second comment starts before the first one has entirely entered the screen.
+	assert.Equal(t, `Dialogue: 0,0:00:02.50,0:00:07.50,Chat,,0,0,0,,{\move(1920,54,-297,54)}{\c&HFF0000&}Other{\c&HFFFFFF&}: <hi>`, dialogues[1])
+}
+
+func TestASS_Escape(t *testing.T) {
+	var buf bytes.Buffer
+	escaped := []twitch.Comment{{UserLogin: "user", UserName: "User", UserColor: "#FFFFFF", Fragments: []twitch.Fragment{{Text: `{\b1}bold\N`}}}}
+	require.NoError(t, chat.ASS(&buf, escaped, chat.Options{}))
+	assert.Contains(t, buf.String(), "User{\\c&HFFFFFF&}: \uFF5B\\\u2060b1\uFF5Dbold\\\u2060N\n")
+}
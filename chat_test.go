@@ -0,0 +1,80 @@
+package twitchdl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rewriteTransport sends every request to the server at URL.
+type rewriteTransport struct {
+	URL *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.URL.Scheme
+	req.URL.Host = t.URL.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func testClient(t *testing.T, srv *httptest.Server) *http.Client {
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	return &http.Client{Transport: rewriteTransport{u}}
+}
+
+func TestChat(t *testing.T) {
+	comment := func(id string, offset int) string {
+		return fmt.Sprintf(`{"cursor":"c%s","node":{"id":"%s","contentOffsetSeconds":%d,"commenter":{"id":"1","login":"user","displayName":"User"},"message":{"userColor":"#FF0000","fragments":[{"text":"hi "},{"text":"Kappa","emote":{"emoteID":"25"}}]}}}`, id, id, offset)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		if payload.Variables["cursor"] == nil {
+			assert.Equal(t, float64(10), payload.Variables["offset"])
+			fmt.Fprintf(w, `{"data":{"video":{"comments":{"edges":[%s,%s],"pageInfo":{"hasNextPage":true}}}}}`,
+				comment("1", 9), comment("2", 10))
+			return
+		}
+		assert.Equal(t, "c2", payload.Variables["cursor"])
+		fmt.Fprintf(w, `{"data":{"video":{"comments":{"edges":[%s,%s,%s],"pageInfo":{"hasNextPage":true}}}}}`,
+			comment("2", 10), comment("3", 15), comment("4", 20))
+	}))
+	defer srv.Close()
+
+	comments, err := Chat(context.Background(), testClient(t, srv), "id", "12345", 10*time.Second, 20*time.Second)
+	require.NoError(t, err)
+	require.Len(t, comments, 2)
+	assert.Equal(t, "2", comments[0].ID)
+	assert.Equal(t, "3", comments[1].ID)
+	assert.Equal(t, "hi Kappa", comments[1].Message())
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteChat(&buf, comments[:1]))
+	assert.JSONEq(t, `{
+		"id":"2",
+		"timestamp":10,
+		"created_at":"0001-01-01T00:00:00Z",
+		"user":{"id":"1","login":"user","display_name":"User","color":"#FF0000"},
+		"emotes":[{"id":"25","begin":3,"end":8}],
+		"fragments":[{"text":"hi "},{"text":"Kappa","emote_id":"25"}],
+		"message":"hi Kappa"
+	}`, strings.TrimSpace(buf.String()))
+}
+
+func TestChat_InvalidTimestamps(t *testing.T) {
+	_, err := Chat(context.Background(), nil, "id", "12345", 10*time.Second, 5*time.Second)
+	assert.Error(t, err)
+}
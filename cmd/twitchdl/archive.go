@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+// archiveChannel downloads every VOD of "channel" matching the filters
+// that is not already inside the "output" directory.
+func archiveChannel(ctx context.Context) {
+	if len(channel) == 0 || len(quality) == 0 {
+		log.Fatalf("-channel and -q are required to archive a channel")
+	}
+	channel = channelName(channel)
+
+	broadcastType := strings.ToUpper(videoType)
+	if broadcastType == "ALL" {
+		broadcastType = ""
+	}
+	var from, to time.Time
+	var err error
+	if len(after) > 0 {
+		if from, err = time.Parse("2006-01-02", after); err != nil {
+			log.Fatalf("Invalid -after date %s: %v", after, err)
+		}
+	}
+	if len(before) > 0 {
+		if to, err = time.Parse("2006-01-02", before); err != nil {
+			log.Fatalf("Invalid -before date %s: %v", before, err)
+		}
+	}
+
+	api := twitch.New(http.DefaultClient, defaultClientID).WithOAuthToken(oauth)
+	var vods []twitch.VOD
+	cursor := ""
+L:
+	for {
+		page, next, err := api.Videos(ctx, channel, broadcastType, cursor)
+		if err != nil {
+			log.Fatalf("Retrieving VODs of channel %s failed: %v", channel, err)
+		}
+		for _, vod := range page {
+			if !from.IsZero() && vod.CreatedAt.Before(from) {
+				// VODs are sorted from the most recent.
+				break L
+			}
+			if !to.IsZero() && !vod.CreatedAt.Before(to) {
+				continue
+			}
+			if vod.Length < minDuration {
+				continue
+			}
+			vods = append(vods, vod)
+		}
+		if len(next) == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	fmt.Printf("%d VODs found for channel %s\n", len(vods), channel)
+	for _, vod := range vods {
+		suffix := fmt.Sprintf(" (%s).mp4", sanitize(quality))
+		name := fmt.Sprintf("%s %s%s", vod.ID, sanitize(vod.Title), suffix)
+		path := filepath.Join(output, name)
+		if archived(filepath.Dir(path), vod.ID, suffix) {
+			fmt.Printf("Skipping: %s\n", name)
+			continue
+		}
+		fmt.Printf("Downloading: %s\n", path)
+		if err := archiveVOD(ctx, vod.ID, path); err != nil {
+			if err == context.Canceled {
+				log.Fatalf("\nDownload of %s interrupted", path)
+			}
+			log.Printf("\nDownloading VOD %s failed: %v", vod.ID, err)
+			continue
+		}
+		fmt.Printf("\rDone%-25s\n", " ")
+	}
+}
+
+// archived reports whether the VOD "id" has already been entirely downloaded
+// into dir: a video named after id and ending with suffix, which holds the
+// quality, exists without a journal. The title of the VOD may have changed since.
+func archived(dir, id, suffix string) bool {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, id+" ") || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, name+".journal")); os.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveVOD downloads the VOD "id" to path using a journal
+// so that an interrupted archive can be resumed.
+func archiveVOD(ctx context.Context, id, path string) error {
+	selected, err := resolveQuality(ctx, id, quality, false)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	journal, err := twitchdl.OpenJournal(path+".journal", fmt.Sprintf("%s %s %v %v", id, selected.Name, time.Duration(0), time.Duration(0)))
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+	if err := journal.Truncate(f); err != nil {
+		return err
+	}
+	opts := append(downloadOptions(), twitchdl.WithJournal(journal))
+	download, err := twitchdl.Download(ctx, http.DefaultClient, defaultClientID, id, selected, 0, 0, opts...)
+	if err != nil {
+		return err
+	}
+	defer download.Close()
+	if _, err := io.Copy(f, &reader{r: download}); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return journal.Remove()
+}
+
+// sanitize removes the characters that are not allowed inside file names.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, name)
+}
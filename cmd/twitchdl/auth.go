@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+// oauthEnv is the environment variable holding the OAuth token.
+const oauthEnv = "TWITCH_OAUTH_TOKEN"
+
+// oauthToken returns the OAuth token given by the -oauth flag, the
+// TWITCH_OAUTH_TOKEN environment variable or the "oauth-token" key of the
+// config file, in this order.
+func oauthToken() (string, error) {
+	if len(oauth) > 0 {
+		return oauth, nil
+	}
+	if token := os.Getenv(oauthEnv); len(token) > 0 {
+		return token, nil
+	}
+	path, err := configPath()
+	if err != nil {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	// The config file holds "key = value" lines. Lines starting with # are ignored.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return "", errors.Errorf("%s: malformed line %q", path, line)
+		}
+		if strings.TrimSpace(kv[0]) == "oauth-token" {
+			return strings.Trim(strings.TrimSpace(kv[1]), `"`), nil
+		}
+	}
+	return "", errors.WithStack(scanner.Err())
+}
+
+// configPath returns the path of the config file:
+// $XDG_CONFIG_HOME/twitchdl/config or ~/.config/twitchdl/config.
+func configPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); len(dir) > 0 {
+		return filepath.Join(dir, "twitchdl", "config"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "twitchdl", "config"), nil
+}
+
+// authOptions returns the options authenticating the requests to the twitch API.
+func authOptions() []twitchdl.Option {
+	return []twitchdl.Option{twitchdl.WithOAuthToken(oauth)}
+}
+
+// authHint returns the instructions to provide an OAuth token if err is an
+// access denied error.
+func authHint(err error) string {
+	if _, ok := errors.Cause(err).(*twitch.AccessDeniedError); !ok {
+		return ""
+	}
+	path, _ := configPath()
+	return "\nProvide the OAuth token of your account with -oauth, the " + oauthEnv +
+		" environment variable or the oauth-token key of " + path + "."
+}
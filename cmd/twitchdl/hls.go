@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+)
+
+// downloadHLS writes each quality of the VOD as an HLS media playlist inside
+// the "output" directory. A master playlist is written when several qualities
+// are requested. It returns the position in the VOD of the first segment.
+func downloadHLS(ctx context.Context) time.Duration {
+	// Each comma separated quality is a selector without fallback.
+	var qualities []twitchdl.Quality
+	for _, expr := range strings.Split(quality, ",") {
+		q, err := resolveQuality(ctx, vodID, expr, false)
+		if err != nil {
+			log.Fatalf("Selecting quality %s failed: %v%s", expr, err, authHint(err))
+		}
+		qualities = append(qualities, q)
+	}
+	var position time.Duration
+	for i, q := range qualities {
+		dir := output
+		if len(qualities) > 1 {
+			dir = filepath.Join(output, q.Name)
+		}
+		download, err := twitchdl.Download(ctx, http.DefaultClient, defaultClientID, vodID, q, start, end, downloadOptions()...)
+		if err != nil {
+			log.Fatalf("Retrieving stream for VOD %s failed: %v%s", vodID, err, authHint(err))
+		}
+		w, err := twitchdl.NewHLSWriter(dir, download)
+		if err != nil {
+			log.Fatalf("Cannot create directory %s: %v", dir, err)
+		}
+		if i == 0 {
+			position = download.Position()
+		}
+		fmt.Printf("Downloading: %s\n", dir)
+		progress := &reader{r: download}
+		for {
+			n, err := w.WriteNext()
+			if err == io.EOF {
+				break
+			}
+			if err == context.Canceled {
+				log.Fatalf("\nDownload of %s interrupted", dir)
+			}
+			if err != nil {
+				log.Fatalf("Writing to directory %s failed: %v", dir, err)
+			}
+			progress.progress(n)
+		}
+		download.Close()
+		if err := w.Close(); err != nil {
+			log.Fatalf("Writing playlist of %s failed: %v", dir, err)
+		}
+		fmt.Printf("\rDone%-25s\n", " ")
+	}
+	if len(qualities) > 1 {
+		if err := twitchdl.WriteHLSMaster(ctx, http.DefaultClient, defaultClientID, vodID, qualities, output, downloadOptions()...); err != nil {
+			log.Fatalf("Writing master playlist of %s failed: %v", output, err)
+		}
+	}
+	return position
+}
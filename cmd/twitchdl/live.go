@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+	"github.com/jybp/twitch-downloader/remux"
+)
+
+// recordLive records the live stream of "channel" until it ends.
+func recordLive(ctx context.Context) {
+	channel = channelName(channel)
+
+	qualities, err := twitchdl.Qualities_live(ctx, http.DefaultClient, defaultClientID, channel, authOptions()...)
+	if err != nil {
+		log.Fatalf("Retrieving qualities for channel %s failed: %v", channel, err)
+	}
+	if len(quality) == 0 {
+		fmt.Printf("%s\n", channel)
+		for _, q := range qualities {
+			fmt.Println(describeQuality(q))
+		}
+		return
+	}
+	selected, err := twitchdl.SelectQuality(qualities, quality)
+	if err != nil {
+		log.Fatalf("Selecting quality %s failed: %v", quality, err)
+	}
+	quality = selected.Name
+
+	recording, err := twitchdl.Record(ctx, http.DefaultClient, defaultClientID, channel, selected, downloadOptions()...)
+	if err != nil {
+		log.Fatalf("Retrieving stream for channel %s failed: %v", channel, err)
+	}
+
+	dir, filename := filepath.Split(output)
+	if len(filename) == 0 {
+		ext := "ts"
+		if len(audio) > 0 {
+			ext = audio
+		} else if remuxMP4 {
+			ext = "mp4"
+		}
+		filename = fmt.Sprintf("%s %s (%s).%s", channel, time.Now().Format("2006-01-02 15-04-05"), quality, ext)
+	}
+	output = filepath.Join(dir, filename)
+
+	f, err := os.OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		log.Fatalf("Cannot create file %s: %v", output, err)
+	}
+
+	fmt.Printf("Recording: %s\n", f.Name())
+
+	var w io.Writer = f
+	conv := newConverter(f)
+	if conv != nil {
+		w = conv
+	}
+	if mp4, ok := conv.(*remux.Writer); ok {
+		// The live stream jumps around the ads, whether they are kept or skipped,
+		// and around the chunks missed between two polls.
+		mp4.Concatenated()
+	}
+	_, err = io.Copy(w, &reader{r: recording, live: true})
+	recording.Close()
+	if err != nil && err != context.Canceled {
+		log.Fatalf("Writing to file %s failed: %v", output, err)
+	}
+	// An interrupted recording is still finalized into a playable file.
+	if conv != nil {
+		if err := conv.Close(); err != nil {
+			log.Fatalf("Remuxing %s failed: %v", output, err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Closing file %s failed: %v", output, err)
+	}
+	fmt.Printf("\rDone%-25s\n", " ")
+}
+
+// channelName extracts the channel name from a channel URL.
+func channelName(s string) string {
+	if u, err := url.Parse(s); err == nil && u.IsAbs() {
+		return path.Base(u.Path)
+	}
+	return s
+}
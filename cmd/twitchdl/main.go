@@ -9,11 +9,14 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"time"
 
 	twitchdl "github.com/jybp/twitch-downloader"
+	twitchchat "github.com/jybp/twitch-downloader/chat"
+	"github.com/jybp/twitch-downloader/remux"
 	"github.com/jybp/twitch-downloader/twitch"
 )
 
@@ -30,23 +33,72 @@ var defaultClientID string
 // command line flags like e.g -vod, -start when running 
 // flag.typeVar(&flagvar, "flagName", "default value", "help messsage of r flag name")
 
-var clientID, vodID, quality, output string
+var clientID, vodID, channel, quality, output, subtitles, audio, ranges, oauth string
 var start, end time.Duration
+var concurrency, retries int
+var resume, metadata, chat, remuxMP4, precise, concat, skipAds, hls, webvtt, unmute, skipMuted bool
+var retryBackoff, retryMaxBackoff time.Duration
+
+// Archive mode flags.
+var archive bool
+var videoType, after, before string
+var minDuration time.Duration
 
 func init() {
 	log.SetFlags(0)
 
 	flag.StringVar(&vodID, "vod", "", `The ID or absolute URL of the twitch VOD to download. https://www.twitch.tv/videos/12345 is the VOD with ID "12345".`)
-	flag.StringVar(&quality, "q", "", "Quality of the VOD to download. Omit this flag to print the available qualities.")
+	flag.StringVar(&channel, "channel", "", `The name or absolute URL of the twitch channel to record live. https://www.twitch.tv/name is the channel "name".`)
+	flag.StringVar(&quality, "q", "", `Quality of the VOD to download: a name, best, worst, source, audio, "<=720p", "max-bandwidth=3M" or a comma separated fallback chain such as "720p60,720p30,best". Omit this flag to print the available qualities.`)
 	flag.StringVar(&output, "o", "", `Path where the VOD will be downloaded. (optional)`)
 	flag.DurationVar(&start, "start", time.Duration(0), "Specify \"start\" to download a subset of the VOD. Example: 1h23m45s (optional)")
 	flag.DurationVar(&end, "end", time.Duration(0), "Specify \"end\" to download a subset of the VOD. Example: 1h34m56s (optional)")
+	flag.BoolVar(&hls, "hls", false, "Write the chunks of the VOD into the -o directory along with an index.m3u8 playlist. Several comma separated qualities can be given to -q, each one selected without fallback. (optional)")
+	flag.StringVar(&ranges, "ranges", "", "Download several sections of the VOD, one file per section along with its chat replay and subtitles unless -concat is set. Not available for clips. Example: 10m-15m,1h2m-1h10m (optional)")
+	flag.BoolVar(&concat, "concat", false, "Concatenate the sections of -ranges into a single output. Cannot be used with -chat, -subtitles or -webvtt. (optional)")
+	flag.BoolVar(&skipAds, "skip-ads", false, "Drop the ads stitched by twitch into the live stream recorded with -channel. (optional)")
+	flag.BoolVar(&unmute, "unmute", false, "Try to download the original audio of the chunks muted by twitch. Falls back to the muted chunks. (optional)")
+	flag.BoolVar(&skipMuted, "skip-muted", false, "Drop the chunks muted by twitch. (optional)")
+	flag.IntVar(&concurrency, "concurrency", 4, "Number of chunks downloaded in parallel. (optional)")
+	flag.IntVar(&retries, "retries", twitchdl.DefaultRetryPolicy.MaxAttempts, "Maximum number of attempts per chunk. (optional)")
+	flag.DurationVar(&retryBackoff, "retry-backoff", twitchdl.DefaultRetryPolicy.MinBackoff, "Delay before retrying a failed chunk. Doubles after every attempt. (optional)")
+	flag.DurationVar(&retryMaxBackoff, "retry-max-backoff", twitchdl.DefaultRetryPolicy.MaxBackoff, "Maximum delay between two attempts. (optional)")
+	flag.BoolVar(&resume, "resume", false, "Resume an interrupted download of the same VOD, quality and timestamps into the same output. A non-empty output without its journal is left untouched. (optional)")
+	flag.BoolVar(&metadata, "metadata", false, "Write the informations of the VOD/Clip next to the output as JSON. (optional)")
+	flag.BoolVar(&chat, "chat", false, "Write the chat replay of the VOD next to the output as JSON lines. Respects -start and -end. (optional)")
+	flag.StringVar(&subtitles, "subtitles", "", "Render the chat replay of the VOD next to the output as subtitles. Comma separated list of ass, srt or vtt. Respects -start and -end. (optional)")
+	flag.BoolVar(&webvtt, "webvtt", false, "Download the subtitles renditions of the VOD next to the output as WebVTT, with the cues rebased on the start of the video. Respects -start and -end. (optional)")
+	flag.BoolVar(&remuxMP4, "remux", false, "Remux the downloaded MPEG-TS stream into a seekable MP4 file. Cannot be used with -resume. (optional)")
+	flag.BoolVar(&precise, "precise", false, "Trim the VOD to the keyframe at or before -start and to -end instead of whole chunks. Requires -remux or -audio m4a. Not available for clips. (optional)")
+	flag.StringVar(&audio, "audio", "", "Extract the audio of the stream as aac or m4a. Not available for clips. Cannot be used with -resume or -remux. (optional)")
+	flag.StringVar(&oauth, "oauth", "", "OAuth token of your twitch account to download subscriber-only or private VODs. Defaults to the TWITCH_OAUTH_TOKEN environment variable or the oauth-token key of ~/.config/twitchdl/config. (optional)")
 	flag.StringVar(&clientID, "client-id", "", "Use a specific twitch.tv API client ID. (optional)")
+	flag.StringVar(&videoType, "type", "archive", "archive mode: Type of the VODs to archive: archive, highlight, upload or all. (optional)")
+	flag.StringVar(&after, "after", "", "archive mode: Only archive the VODs created on or after this date. Example: 2020-01-31 (optional)")
+	flag.StringVar(&before, "before", "", "archive mode: Only archive the VODs created before this date. Example: 2020-02-29 (optional)")
+	flag.DurationVar(&minDuration, "min-duration", time.Duration(0), "archive mode: Only archive the VODs lasting at least this long. Example: 30m (optional)")
+
+	// "twitchdl archive -channel name" downloads all the VODs of a channel.
+	if len(os.Args) > 1 && os.Args[1] == "archive" {
+		archive = true
+		flag.CommandLine.Parse(os.Args[2:])
+		return
+	}
 	flag.Parse()
 }
 
 func main() {
 
+	// Cancel the download on interrupt so that it can be resumed with -resume.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
 	isClip := false
 	if len(clientID) > 0 {
 		defaultClientID = clientID
@@ -55,7 +107,58 @@ func main() {
 	if len(defaultClientID) == 0 {
 		panic("no default client id specified")
 	}
+	token, err := oauthToken()
+	if err != nil {
+		log.Fatalf("Reading OAuth token failed: %v", err)
+	}
+	oauth = token
 	
+	if remuxMP4 && resume {
+		log.Fatalf("-remux cannot be used with -resume")
+	}
+	if len(audio) > 0 {
+		audio = strings.ToLower(audio)
+		if audio != "aac" && audio != "m4a" {
+			log.Fatalf("Unsupported -audio format %s", audio)
+		}
+		if resume || remuxMP4 {
+			log.Fatalf("-audio cannot be used with -resume or -remux")
+		}
+	}
+	if precise && !remuxMP4 && audio != "m4a" {
+		log.Fatalf("-precise requires -remux or -audio m4a")
+	}
+	if hls && (resume || remuxMP4 || len(audio) > 0 || len(ranges) > 0) {
+		log.Fatalf("-hls cannot be used with -resume, -remux, -audio or -ranges")
+	}
+	var sections []twitchdl.Range
+	if len(ranges) > 0 {
+		var err error
+		if sections, err = twitchdl.ParseRanges(ranges); err != nil {
+			log.Fatalf("Invalid -ranges: %v", err)
+		}
+		switch {
+		case start > 0 || end > 0:
+			log.Fatalf("-ranges cannot be used with -start or -end")
+		case resume && !concat:
+			log.Fatalf("-resume requires -concat when used with -ranges")
+		case precise && concat:
+			log.Fatalf("-precise cannot be used with -concat")
+		case concat && (chat || len(subtitles) > 0 || webvtt):
+			log.Fatalf("-chat, -subtitles and -webvtt cannot be used with -concat")
+		}
+	}
+
+	if archive {
+		archiveChannel(ctx)
+		return
+	}
+
+	if len(channel) > 0 {
+		recordLive(ctx)
+		return
+	}
+
 	if len(vodID) == 0 {
 		flag.PrintDefaults()
 		return
@@ -74,14 +177,24 @@ func main() {
 			//fmt.Println(id)
 		} 
 	}
+	// Clips are served as MP4 and are written as is.
+	if isClip && (len(audio) > 0 || precise || len(ranges) > 0) {
+		log.Fatalf("-audio, -precise and -ranges are only available for VODs")
+	}
 	
-	var vod twitch.VOD
-	api := twitch.New(http.DefaultClient, defaultClientID)
+	// title is the name of the output and info is written to the metadata sidecar.
+	var title string
+	var info interface{}
+	api := twitch.New(http.DefaultClient, defaultClientID).WithOAuthToken(oauth)
 	//fmt.Println(api)
 	if isClip{
-		vod, err = api.Clip(context.Background(), vodID)
+		var clip twitch.ClipInfo
+		clip, err = api.Clip(ctx, vodID)
+		title, info = fmt.Sprintf("%s - %s", clip.ChannelName, clip.Title), clipMetadata(clip)
 	} else{
-		vod, err = api.VOD(context.Background(), vodID)
+		var vod twitch.VOD
+		vod, err = api.VOD(ctx, vodID)
+		title, info = vod.Title, vodMetadata(vod)
 	}
 
 	if err != nil {
@@ -93,67 +206,338 @@ func main() {
 	}
 
 	if len(quality) == 0 {
-		var qualities []string
+		var qualities []twitchdl.Quality
 		if isClip{
-			qualities, err = twitchdl.Qualities_clip(context.Background(), http.DefaultClient, defaultClientID, vodID)
+			qualities, err = twitchdl.Qualities_clip(ctx, http.DefaultClient, defaultClientID, vodID, authOptions()...)
 		} else{
-			qualities, err = twitchdl.Qualities(context.Background(), http.DefaultClient, defaultClientID, vodID)
+			qualities, err = twitchdl.Qualities(ctx, http.DefaultClient, defaultClientID, vodID, authOptions()...)
 		}
 
 		if err != nil {
 			if isClip{
-				log.Fatalf("Retrieving qualities for Clip %s failed: %v", vodID, err)
+				log.Fatalf("Retrieving qualities for Clip %s failed: %v%s", vodID, err, authHint(err))
 			} else{
-				log.Fatalf("Retrieving qualities for VOD %s failed: %v", vodID, err)
+				log.Fatalf("Retrieving qualities for VOD %s failed: %v%s", vodID, err, authHint(err))
 			}
 		}
-		fmt.Printf("%s\n%s\n", vod.Title, strings.Join(qualities, "\n"))
+		fmt.Printf("%s\n", title)
+		for _, q := range qualities {
+			fmt.Println(describeQuality(q))
+		}
 		return
 	}
 
-	var download *twitchdl.Merger
-	if isClip{
-		download, err = twitchdl.Download_clip(context.Background(), http.DefaultClient, defaultClientID, vodID, quality)
-		if err != nil {
-			log.Fatalf("Retrieving stream for Clip %s failed: %v", vodID, err)
-		}
-	} else{
-
-		download, err = twitchdl.Download(context.Background(), http.DefaultClient, defaultClientID, vodID, quality, start, end)
+	// selected keeps the group of the quality, whose name may be shared by several groups.
+	var selected twitchdl.Quality
+	if !hls {
+		selected, err = resolveQuality(ctx, vodID, quality, isClip)
 		if err != nil {
-			log.Fatalf("Retrieving stream for VOD %s failed: %v", vodID, err)
+			log.Fatalf("Selecting quality %s failed: %v%s", quality, err, authHint(err))
 		}
+		quality = selected.Name
 	}
 
 	path, filename := filepath.Split(output)
 	if len(filename) == 0 {
 		ext := "mp4"
-		if strings.Contains(strings.ToLower(quality), "audio") {
+		if len(audio) > 0 {
+			ext = audio
+		} else if strings.HasPrefix(quality, twitchdl.SubtitlesPrefix) {
+			ext = "vtt"
+		} else if strings.Contains(strings.ToLower(quality), "audio") && !remuxMP4 {
 			ext = "mp4a"
 		}
-		filename = fmt.Sprintf("%s (%s).%s", vod.Title, quality, ext)
+		filename = fmt.Sprintf("%s (%s).%s", title, quality, ext)
+		if hls {
+			filename = fmt.Sprintf("%s (%s)", title, quality)
+		}
 	}
 	output = filepath.Join(path, filename)
 
-	f, err := os.OpenFile(output, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if metadata {
+		if vod, ok := info.(vodMetadataJSON); ok && !hls {
+			if vod.MutedRanges, err = mutedRanges(ctx, selected); err != nil {
+				log.Fatalf("Retrieving muted sections of VOD %s failed: %v", vodID, err)
+			}
+			info = vod
+		}
+		if err := writeMetadata(output+".json", info); err != nil {
+			log.Fatalf("Writing metadata of %s failed: %v", output, err)
+		}
+	}
+
+	if hls {
+		if isClip {
+			log.Fatalf("-hls is only available for VODs")
+		}
+		position := downloadHLS(ctx)
+		writeSidecars(ctx, output, twitchdl.Range{Start: start, End: end}, position)
+		return
+	}
+
+	if len(sections) > 0 && !concat {
+		downloadRanges(ctx, sections, selected)
+		return
+	}
+
+	opts := downloadOptions()
+
+	flags := os.O_RDWR | os.O_CREATE | os.O_EXCL
+	if resume {
+		if err := resumable(output); err != nil {
+			log.Fatalf("Cannot resume %s: %v", output, err)
+		}
+		flags = os.O_RDWR | os.O_CREATE
+	}
+	f, err := os.OpenFile(output, flags, 0666)
 	if err != nil {
 		log.Fatalf("Cannot create file %s: %v", output, err)
 	}
 
+	var journal *twitchdl.Journal
+	if resume {
+		key := fmt.Sprintf("%s %s %v %v", vodID, quality, start, end)
+		if len(sections) > 0 {
+			key = fmt.Sprintf("%s %s %s", vodID, quality, ranges)
+		}
+		journal, err = twitchdl.OpenJournal(output+".journal", key)
+		if err != nil {
+			log.Fatalf("Cannot open journal for %s: %v", output, err)
+		}
+		if err := journal.Truncate(f); err != nil {
+			log.Fatalf("Cannot resume %s: %v", output, err)
+		}
+		opts = append(opts, twitchdl.WithJournal(journal))
+	}
+
+	var download *twitchdl.Merger
+	if isClip{
+		download, err = twitchdl.Download_clip(ctx, http.DefaultClient, defaultClientID, vodID, selected, opts...)
+		if err != nil {
+			log.Fatalf("Retrieving stream for Clip %s failed: %v%s", vodID, err, authHint(err))
+		}
+	} else if len(sections) > 0 {
+		var mergers []*twitchdl.Merger
+		mergers, err = twitchdl.DownloadRanges(ctx, http.DefaultClient, defaultClientID, vodID, selected, sections, opts...)
+		if err != nil {
+			log.Fatalf("Retrieving stream for VOD %s failed: %v%s", vodID, err, authHint(err))
+		}
+		download = twitchdl.Concat(mergers...)
+	} else{
+
+		download, err = twitchdl.Download(ctx, http.DefaultClient, defaultClientID, vodID, selected, start, end, opts...)
+		if err != nil {
+			log.Fatalf("Retrieving stream for VOD %s failed: %v%s", vodID, err, authHint(err))
+		}
+	}
+
 	fmt.Printf("Downloading: %s\n", f.Name())
 
-	if _, err := io.Copy(f, &reader{r: download}); err != nil {
+	// Clips are already served as MP4.
+	raw := isClip || strings.HasPrefix(quality, twitchdl.SubtitlesPrefix)
+	videoStart, err := save(download, f, twitchdl.Range{Start: start, End: end}, raw)
+	if err != nil {
+		if err == context.Canceled {
+			f.Close()
+			log.Fatalf("\nDownload of %s interrupted", output)
+		}
 		log.Fatalf("Writing to file %s failed: %v", output, err)
 	}
 	if err := f.Close(); err != nil {
 		log.Fatalf("Closing file %s failed: %v", output, err)
 	}
+	if journal != nil {
+		if err := journal.Remove(); err != nil {
+			log.Fatalf("Removing journal of %s failed: %v", output, err)
+		}
+	}
 	fmt.Printf("\rDone%-25s\n", " ")
+
+	if !isClip {
+		writeSidecars(ctx, output, twitchdl.Range{Start: start, End: end}, videoStart)
+	}
+}
+
+// resumable returns an error if the download to output cannot be resumed
+// because output is not empty but has no journal, such as a completed download
+// whose journal has been removed, which the journal would otherwise truncate.
+func resumable(output string) error {
+	if _, err := os.Stat(output + ".journal"); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	info, err := os.Stat(output)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() > 0 {
+		return fmt.Errorf("%s.journal not found, remove %s to download it again", output, output)
+	}
+	return nil
+}
+
+// writeSidecars writes the chat replay and the subtitles requested by the flags
+// for the section r of the VOD written to output, which starts at videoStart.
+func writeSidecars(ctx context.Context, output string, r twitchdl.Range, videoStart time.Duration) {
+	base := strings.TrimSuffix(output, filepath.Ext(output))
+	if chat || len(subtitles) > 0 {
+		if err := writeChat(ctx, base, r, videoStart); err != nil {
+			log.Fatalf("Writing chat replay of %s failed: %v", output, err)
+		}
+	}
+	if webvtt {
+		if err := writeWebVTT(ctx, base, r, videoStart); err != nil {
+			log.Fatalf("Writing subtitles of %s failed: %v", output, err)
+		}
+	}
+}
+
+// save writes download to f, converted according to the flags unless raw is true.
+// The output is trimmed to r if -precise is set.
+// It returns the position in the VOD of the beginning of the output.
+func save(download *twitchdl.Merger, f *os.File, r twitchdl.Range, raw bool) (time.Duration, error) {
+	defer download.Close()
+	var w io.Writer = f
+	var conv converter
+	if !raw {
+		if conv = newConverter(f); conv != nil {
+			w = conv
+		}
+	}
+	mp4, ok := conv.(*remux.Writer)
+	if ok && download.Discontinuous() {
+		// The ranges, the chunks left or the discontinuities are joined together.
+		mp4.Concatenated()
+	}
+	trim := ok && precise
+	if trim {
+		// The timestamps of the remuxer are relative to the first chunk.
+		trimEnd := r.End
+		if r.End > 0 {
+			trimEnd = r.End - download.Position()
+		}
+		mp4.Trim(r.Start-download.Position(), trimEnd)
+	}
+	if _, err := io.Copy(w, &reader{r: download}); err != nil {
+		return 0, err
+	}
+	if conv != nil {
+		if err := conv.Close(); err != nil {
+			return 0, fmt.Errorf("remuxing failed: %v", err)
+		}
+	}
+	if !trim {
+		return download.Position(), nil
+	}
+	from, to := mp4.Range()
+	fmt.Printf("\rTrimmed from %v to %v%-10s\n",
+		(download.Position() + from).Round(time.Millisecond),
+		(download.Position() + to).Round(time.Millisecond), " ")
+	return download.Position() + from, nil
+}
+
+// writeChat writes the chat replay of the VOD to base followed by the
+// extension of each requested format. The video written for the section r
+// starts at videoStart, which precedes r.Start unless it is trimmed precisely,
+// so the chat is retrieved and rebased from there.
+func writeChat(ctx context.Context, base string, r twitchdl.Range, videoStart time.Duration) error {
+	comments, err := twitchdl.Chat(ctx, http.DefaultClient, defaultClientID, vodID, videoStart, r.End, authOptions()...)
+	if err != nil {
+		return err
+	}
+	renderers := map[string]func(io.Writer, []twitch.Comment, twitchchat.Options) error{
+		"chat.jsonl": func(w io.Writer, comments []twitch.Comment, _ twitchchat.Options) error {
+			return twitchdl.WriteChat(w, comments)
+		},
+		"ass": twitchchat.ASS,
+		"srt": twitchchat.SRT,
+		"vtt": twitchchat.WebVTT,
+	}
+	var formats []string
+	if chat {
+		formats = append(formats, "chat.jsonl")
+	}
+	if len(subtitles) > 0 {
+		formats = append(formats, strings.Split(strings.ToLower(subtitles), ",")...)
+	}
+	for _, format := range formats {
+		render, ok := renderers[format]
+		if !ok {
+			return fmt.Errorf("unsupported subtitles format %s", format)
+		}
+		path := base + "." + format
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		if err := render(f, comments, twitchchat.Options{Start: videoStart}); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Chat replay: %s (%d messages)\n", path, len(comments))
+	}
+	return nil
+}
+
+// downloadOptions returns the options set by the flags.
+func downloadOptions() []twitchdl.Option {
+	opts := append(authOptions(),
+		twitchdl.WithConcurrency(concurrency),
+		twitchdl.WithRetry(twitchdl.RetryPolicy{
+			MaxAttempts: retries,
+			MinBackoff:  retryBackoff,
+			MaxBackoff:  retryMaxBackoff,
+		}),
+	)
+	if skipAds {
+		opts = append(opts, twitchdl.WithSkipAds())
+	}
+	if unmute {
+		opts = append(opts, twitchdl.WithUnmuted())
+	}
+	if skipMuted {
+		opts = append(opts, twitchdl.WithSkipMuted())
+	}
+	return opts
+}
+
+// converter converts the MPEG-TS stream written to it.
+type converter interface {
+	io.Writer
+	Close() error
+}
+
+// newConverter returns the converter writing to w requested by the -remux
+// and -audio flags or nil if the MPEG-TS stream must be written as is.
+func newConverter(w io.Writer) converter {
+	switch {
+	case audio == "aac":
+		return remux.NewADTSWriter(w)
+	case audio == "m4a":
+		return remux.NewAudioWriter(w)
+	case remuxMP4:
+		return remux.NewWriter(w)
+	}
+	return nil
+}
+
+// chunkReader is implemented by twitchdl.Merger and twitchdl.Recorder.
+type chunkReader interface {
+	io.Reader
+	Chunks() int
+	Current() int
 }
 
 // reader prints the download progress every second.
+// The number of chunks is printed instead of a percentage when live is true.
 type reader struct {
-	r *twitchdl.Merger
+	r    chunkReader
+	live bool
 
 	from time.Time
 	n    uint64
@@ -162,8 +546,23 @@ type reader struct {
 
 func (r *reader) Read(p []byte) (n int, err error) {
 	n, err = r.r.Read(p)
+	r.progress(n)
+	return
+}
+
+// progress accounts for n more bytes and prints the progress.
+func (r *reader) progress(n int) {
 	r.n += uint64(n)
 	r.t += uint64(n)
+	if r.live && time.Now().Sub(r.from) > time.Second {
+		fmt.Printf("\r%-12s %-10s %d chunks",
+			r.btos(r.bitrate())+"/s",
+			r.btos(r.t),
+			r.r.Current())
+		r.from = time.Now()
+		r.n = 0
+		return
+	}
 	if time.Now().Sub(r.from) > time.Second {
 		progress := float64(r.r.Current()) * 100 / float64(r.r.Chunks())
 		fmt.Printf("\r%-12s %-10s %-2d%%",
@@ -173,7 +572,6 @@ func (r *reader) Read(p []byte) (n int, err error) {
 		r.from = time.Now()
 		r.n = 0
 	}
-	return
 }
 
 func (*reader) btos(b uint64) string {
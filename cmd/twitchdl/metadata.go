@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+// writeMetadata writes v as indented JSON to path.
+func writeMetadata(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0666)
+}
+
+type gameMetadata struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type mutedSegmentMetadata struct {
+	Offset   float64 `json:"offset"`
+	Duration float64 `json:"duration"`
+}
+
+// vodMetadataJSON is the JSON sidecar of a VOD. Durations are in seconds.
+type vodMetadataJSON struct {
+	ID            string                 `json:"id"`
+	Title         string                 `json:"title"`
+	Description   string                 `json:"description,omitempty"`
+	ChannelLogin  string                 `json:"channel_login"`
+	ChannelName   string                 `json:"channel_name"`
+	BroadcastType string                 `json:"broadcast_type"`
+	CreatedAt     time.Time              `json:"created_at"`
+	PublishedAt   time.Time              `json:"published_at"`
+	Length        float64                `json:"length"`
+	ViewCount     int                    `json:"view_count"`
+	Game          gameMetadata           `json:"game"`
+	PreviewURL    string                 `json:"preview_url,omitempty"`
+	ThumbnailURLs []string               `json:"thumbnail_urls,omitempty"`
+	MutedSegments []mutedSegmentMetadata `json:"muted_segments,omitempty"`
+	// MutedRanges are the muted sections between -start and -end found in the playlist.
+	MutedRanges []rangeMetadata `json:"muted_ranges,omitempty"`
+}
+
+type rangeMetadata struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// mutedRanges returns the sections of the VOD with quality q between -start
+// and -end whose chunks are muted.
+func mutedRanges(ctx context.Context, q twitchdl.Quality) ([]rangeMetadata, error) {
+	download, err := twitchdl.Download(ctx, http.DefaultClient, defaultClientID, vodID, q, start, end, authOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	defer download.Close()
+	var ranges []rangeMetadata
+	for _, r := range download.Muted() {
+		ranges = append(ranges, rangeMetadata{r.Start.Seconds(), r.End.Seconds()})
+	}
+	return ranges, nil
+}
+
+func vodMetadata(vod twitch.VOD) vodMetadataJSON {
+	m := vodMetadataJSON{
+		ID:            vod.ID,
+		Title:         vod.Title,
+		Description:   vod.Description,
+		ChannelLogin:  vod.ChannelLogin,
+		ChannelName:   vod.ChannelName,
+		BroadcastType: vod.BroadcastType,
+		CreatedAt:     vod.CreatedAt,
+		PublishedAt:   vod.PublishedAt,
+		Length:        vod.Length.Seconds(),
+		ViewCount:     vod.ViewCount,
+		Game:          gameMetadata{vod.Game.ID, vod.Game.Name},
+		PreviewURL:    vod.PreviewURL,
+		ThumbnailURLs: vod.ThumbnailURLs,
+	}
+	for _, muted := range vod.MutedSegments {
+		m.MutedSegments = append(m.MutedSegments, mutedSegmentMetadata{muted.Offset.Seconds(), muted.Duration.Seconds()})
+	}
+	return m
+}
+
+// clipMetadataJSON is the JSON sidecar of a Clip. Durations are in seconds.
+type clipMetadataJSON struct {
+	ID           string       `json:"id"`
+	Slug         string       `json:"slug"`
+	Title        string       `json:"title"`
+	URL          string       `json:"url"`
+	ChannelLogin string       `json:"channel_login"`
+	ChannelName  string       `json:"channel_name"`
+	CuratorLogin string       `json:"curator_login,omitempty"`
+	CuratorName  string       `json:"curator_name,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Duration     float64      `json:"duration"`
+	ViewCount    int          `json:"view_count"`
+	Game         gameMetadata `json:"game"`
+	VideoID      string       `json:"video_id,omitempty"`
+	VideoOffset  float64      `json:"video_offset,omitempty"`
+}
+
+func clipMetadata(clip twitch.ClipInfo) clipMetadataJSON {
+	return clipMetadataJSON{
+		ID:           clip.ID,
+		Slug:         clip.Slug,
+		Title:        clip.Title,
+		URL:          clip.URL,
+		ChannelLogin: clip.ChannelLogin,
+		ChannelName:  clip.ChannelName,
+		CuratorLogin: clip.CuratorLogin,
+		CuratorName:  clip.CuratorName,
+		CreatedAt:    clip.CreatedAt,
+		Duration:     clip.Duration.Seconds(),
+		ViewCount:    clip.ViewCount,
+		Game:         gameMetadata{clip.Game.ID, clip.Game.Name},
+		VideoID:      clip.VideoID,
+		VideoOffset:  clip.VideoOffset.Seconds(),
+	}
+}
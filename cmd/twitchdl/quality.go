@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+)
+
+// resolveQuality returns the quality of the VOD or Clip "id" selected by the
+// expression expr. See twitchdl.SelectQuality for the syntax.
+func resolveQuality(ctx context.Context, id, expr string, isClip bool) (twitchdl.Quality, error) {
+	var qualities []twitchdl.Quality
+	var err error
+	if isClip {
+		qualities, err = twitchdl.Qualities_clip(ctx, http.DefaultClient, defaultClientID, id, authOptions()...)
+	} else {
+		qualities, err = twitchdl.Qualities(ctx, http.DefaultClient, defaultClientID, id, authOptions()...)
+	}
+	if err != nil {
+		return twitchdl.Quality{}, err
+	}
+	return twitchdl.SelectQuality(qualities, expr)
+}
+
+// describeQuality returns the name of q followed by its characteristics.
+func describeQuality(q twitchdl.Quality) string {
+	var details []string
+	if q.Width > 0 && q.Height > 0 {
+		details = append(details, fmt.Sprintf("%dx%d", q.Width, q.Height))
+	} else if q.Height > 0 {
+		details = append(details, fmt.Sprintf("%dp", q.Height))
+	}
+	if q.FPS > 0 {
+		details = append(details, fmt.Sprintf("%gfps", q.FPS))
+	}
+	if q.Bandwidth > 0 {
+		details = append(details, fmt.Sprintf("%dkbps", q.Bandwidth/1000))
+	}
+	if len(q.Codecs) > 0 {
+		details = append(details, strings.Join(q.Codecs, ","))
+	}
+	if q.AudioOnly {
+		details = append(details, "audio only")
+	}
+	if q.Source {
+		details = append(details, "source")
+	}
+	if len(details) == 0 {
+		return q.Name
+	}
+	return fmt.Sprintf("%-20s %s", q.Name, strings.Join(details, " "))
+}
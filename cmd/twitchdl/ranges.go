@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+)
+
+// downloadRanges downloads each section of the VOD with quality q to its own
+// file named after "output", along with its chat replay and subtitles.
+func downloadRanges(ctx context.Context, sections []twitchdl.Range, q twitchdl.Quality) {
+	mergers, err := twitchdl.DownloadRanges(ctx, http.DefaultClient, defaultClientID, vodID, q, sections, downloadOptions()...)
+	if err != nil {
+		log.Fatalf("Retrieving stream for VOD %s failed: %v%s", vodID, err, authHint(err))
+	}
+	for i, download := range mergers {
+		path := rangeOutput(output, sections[i])
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if err != nil {
+			log.Fatalf("Cannot create file %s: %v", path, err)
+		}
+		fmt.Printf("Downloading: %s\n", f.Name())
+		videoStart, err := save(download, f, sections[i], false)
+		if err != nil {
+			if err == context.Canceled {
+				f.Close()
+				log.Fatalf("\nDownload of %s interrupted", path)
+			}
+			log.Fatalf("Writing to file %s failed: %v", path, err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalf("Closing file %s failed: %v", path, err)
+		}
+		fmt.Printf("\rDone%-25s\n", " ")
+		writeSidecars(ctx, path, sections[i], videoStart)
+	}
+}
+
+// rangeOutput inserts the section r before the extension of path.
+func rangeOutput(path string, r twitchdl.Range) string {
+	ext := filepath.Ext(path)
+	end := "end"
+	if r.End > 0 {
+		end = r.End.String()
+	}
+	return fmt.Sprintf("%s [%v-%s]%s", strings.TrimSuffix(path, ext), r.Start, end, ext)
+}
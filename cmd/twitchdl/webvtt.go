@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	twitchdl "github.com/jybp/twitch-downloader"
+)
+
+// writeWebVTT downloads every subtitles rendition of the section r of the VOD
+// next to the output as "<base>.<name>.vtt". The cues are rebased on
+// videoStart, the position in the VOD of the beginning of the video.
+func writeWebVTT(ctx context.Context, base string, r twitchdl.Range, videoStart time.Duration) error {
+	qualities, err := twitchdl.Qualities(ctx, http.DefaultClient, defaultClientID, vodID, authOptions()...)
+	if err != nil {
+		return err
+	}
+	for _, q := range qualities {
+		if !strings.HasPrefix(q.Name, twitchdl.SubtitlesPrefix) {
+			continue
+		}
+		name := strings.NewReplacer("/", "_", `\`, "_").Replace(strings.TrimPrefix(q.Name, twitchdl.SubtitlesPrefix))
+		download, err := twitchdl.Download(ctx, http.DefaultClient, defaultClientID, vodID, q, r.Start, r.End, downloadOptions()...)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadAll(download)
+		download.Close()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(base+"."+name+".vtt", twitchdl.RebaseWebVTT(b, videoStart), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
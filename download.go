@@ -5,8 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
+	"sync"
 	"time"
 	//"net/http/httputil"
 	"github.com/jybp/twitch-downloader/m3u8"
@@ -16,34 +18,29 @@ import (
 
 
 // Qualities return the qualities available for the Clip "vodID".
-func Qualities_clip(ctx context.Context, client *http.Client, clientID, vodID string) ([]string, error) {
-	var qualities []string
-	api := twitch.New(client, clientID)
-	clip_info,err :=api.Clip_url(context.Background(), vodID)
+func Qualities_clip(ctx context.Context, client *http.Client, clientID, vodID string, opts ...Option) ([]Quality, error) {
+	api := newAPI(client, clientID, newOptions(opts))
+	clip_info, err := api.Clip(ctx, vodID)
 	if err != nil {
 		return nil, err
 	}
-
-	for _,v:= range clip_info{
-		qualities = append(qualities,v.Quality_option)
-	}
-
-	return qualities, nil
+	return clipQualities(clip_info.Qualities), nil
 }
 
 
 // Download sets up the download of the Clip "vodId" with quality "quality"
 // using the provided http.Client.
 // The download is actually perfomed when the returned io.Reader is being read.
-func Download_clip(ctx context.Context, client *http.Client, clientID, vodID, quality string) (r *Merger, err error) {
-	api := twitch.New(client, clientID)
-	clip_info,err :=api.Clip_url(context.Background(), vodID)
+func Download_clip(ctx context.Context, client *http.Client, clientID, vodID string, quality Quality, opts ...Option) (r *Merger, err error) {
+	o := newOptions(opts)
+	api := newAPI(client, clientID, o)
+	clip_info, err := api.Clip(ctx, vodID)
 	if err != nil {
 		return nil, err
 	}
 	var variant *twitch.Clip
-	for _,v:= range clip_info{
-		if v.Quality_option != quality {
+	for _,v:= range clip_info.Qualities{
+		if v.Quality_option != quality.Name {
 			continue
 		}
 		variant = &v
@@ -56,7 +53,7 @@ func Download_clip(ctx context.Context, client *http.Client, clientID, vodID, qu
 
 	tok, sig, err := api.ClipToken(ctx, vodID)
 	if err != nil {
-		return nil, errors.Errorf("something went wrong getting authenticated clip url [%s]: \n%v", variant.SourceURL, err)
+		return nil, errors.Wrapf(err, "something went wrong getting authenticated clip url [%s]", variant.SourceURL)
 	}
 
 	auth_source_url := fmt.Sprintf("%s?sig=%s&token=%s", variant.SourceURL, sig, tok)
@@ -65,17 +62,23 @@ func Download_clip(ctx context.Context, client *http.Client, clientID, vodID, qu
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	req = req.WithContext(ctx)
 
 	var downloadFns []downloadFunc
-	downloadFns = append(downloadFns, prepare(client, req))
+	downloadFns = append(downloadFns, prepare(client, req, o.retry))
+	numbers := []int{0}
+	if _, ok := o.journal.Last(); ok {
+		// The clip has already been written entirely.
+		downloadFns, numbers = nil, nil
+	}
 
-	return &Merger{downloads: downloadFns}, nil
+	return newMerger(ctx, downloadFns, numbers, o), nil
 }
 
 
 // Qualities return the qualities available for the VOD "vodID".
-func Qualities(ctx context.Context, client *http.Client, clientID, vodID string) ([]string, error) {
-	api := twitch.New(client, clientID)
+func Qualities(ctx context.Context, client *http.Client, clientID, vodID string, opts ...Option) ([]Quality, error) {
+	api := newAPI(client, clientID, newOptions(opts))
 	m3u8raw, err := api.M3U8(ctx, vodID)
 	if err != nil {
 		return nil, err
@@ -84,72 +87,138 @@ func Qualities(ctx context.Context, client *http.Client, clientID, vodID string)
 	if err != nil {
 		return nil, err
 	}
-	var qualities []string
-	for _, variant := range master.Variants {
-		for _, alt := range variant.Alternatives {
-			qualities = append(qualities, alt.Name)
+	return vodQualities(master), nil
+}
+
+// findVariant returns the variant of master with a video or audio alternative
+// named quality.Name. The group of the alternative must match quality.GroupID if set.
+func findVariant(master m3u8.MasterPlaylist, quality Quality) (m3u8.Variant, error) {
+	for _, v := range master.Variants {
+		for _, alt := range v.Alternatives {
+			if alt.Type != "VIDEO" && alt.Type != "AUDIO" {
+				continue
+			}
+			if alt.Name == quality.Name && (len(quality.GroupID) == 0 || alt.GroupID == quality.GroupID) {
+				return v, nil
+			}
 		}
 	}
-	return qualities, nil
+	return m3u8.Variant{}, errors.Errorf("quality %s not found", quality)
 }
 
-// Download sets up the download of the VOD "vodId" with quality "quality"
-// using the provided http.Client.
-// The download is actually perfomed when the returned io.Reader is being read.
-func Download(ctx context.Context, client *http.Client, clientID, vodID, quality string, start, end time.Duration) (r *Merger, err error) {
-	api := twitch.New(client, clientID)
-	m3u8raw, err := api.M3U8(ctx, vodID)
+// fetchMedia retrieves and parses the media playlist at URL.
+func fetchMedia(ctx context.Context, client *http.Client, URL string) (m3u8.MediaPlaylist, error) {
+	req, err := http.NewRequest(http.MethodGet, URL, nil)
 	if err != nil {
-		return nil, err
+		return m3u8.MediaPlaylist{}, errors.WithStack(err)
 	}
-	master, err := m3u8.Master(bytes.NewReader(m3u8raw))
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return m3u8.MediaPlaylist{}, errors.WithStack(err)
 	}
-
-	var variant m3u8.Variant
-L:
-	for _, v := range master.Variants {
-		for _, alt := range v.Alternatives {
-			if alt.Name != quality {
-				continue
-			}
-			variant = v
-			break L
-		}
+	defer resp.Body.Close()
+	if s := resp.StatusCode; s < 200 || s >= 300 {
+		return m3u8.MediaPlaylist{}, errors.WithStack(newStatusError(resp))
 	}
+	return m3u8.Media(resp.Body, URL)
+}
 
-	if len(variant.URL) == 0 {
-		return nil, errors.Errorf("quality %s not found", quality)
+// Download sets up the download of the VOD "vodId" with quality "quality"
+// using the provided http.Client.
+// The subtitles returned by Qualities are downloaded as a single WebVTT file.
+// The download is actually perfomed when the returned io.Reader is being read.
+func Download(ctx context.Context, client *http.Client, clientID, vodID string, quality Quality, start, end time.Duration, opts ...Option) (r *Merger, err error) {
+	o := newOptions(opts)
+	media, err := vodMedia(ctx, client, clientID, vodID, quality, o)
+	if err != nil {
+		return nil, err
 	}
+	return newDownload(ctx, client, media, start, end, o)
+}
 
-	mediaResp, err := client.Get(variant.URL)
+// vodMedia retrieves the media playlist of the VOD "vodID" with quality "quality".
+func vodMedia(ctx context.Context, client *http.Client, clientID, vodID string, quality Quality, o options) (m3u8.MediaPlaylist, error) {
+	api := newAPI(client, clientID, o)
+	m3u8raw, err := api.M3U8(ctx, vodID)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return m3u8.MediaPlaylist{}, err
 	}
-	defer mediaResp.Body.Close()
-	media, err := m3u8.Media(mediaResp.Body, variant.URL)
+	master, err := m3u8.Master(bytes.NewReader(m3u8raw))
 	if err != nil {
-		return nil, err
+		return m3u8.MediaPlaylist{}, err
+	}
+	URL, err := mediaURL(master, quality)
+	if err != nil {
+		return m3u8.MediaPlaylist{}, err
 	}
+	return fetchMedia(ctx, client, URL)
+}
 
+// newDownload returns a Merger of the segments of media between start and end.
+func newDownload(ctx context.Context, client *http.Client, media m3u8.MediaPlaylist, start, end time.Duration, o options) (*Merger, error) {
 	var downloadFns []downloadFunc
-	segments, err := sliceSegments(media.Segments, start, end)
+	segments, err := sliceSegments(media, start, end)
 	if err != nil {
 		return nil, err
 	}
+	var numbers []int
+	last, resume := o.journal.Last()
+	var initMap *m3u8.Map
+	var downloaded []m3u8.MediaSegment
+	var skipped, discontinuous bool
 	for _, segment := range segments {
-		req, err := http.NewRequest(http.MethodGet, segment.URL, nil)
+		// The Media Initialization Section is written before the first
+		// segment and whenever it changes, usually after a discontinuity.
+		withMap := !sameMap(initMap, segment.Map)
+		if resume && segment.Number <= last.Number {
+			// The segment and its Media Initialization Section have already been written.
+			initMap = segment.Map
+			continue
+		}
+		if segment.Gap || (segment.Muted && o.skipMuted) {
+			skipped = true
+			continue
+		}
+		if len(downloadFns) > 0 && (skipped || segment.Discontinuity) {
+			discontinuous = true
+		}
+		skipped = false
+		initMap = segment.Map
+		download := segmentDownload
+		if segment.Muted && o.unmute {
+			download = unmutedDownload
+		}
+		fn, err := download(ctx, client, segment, withMap, o.retry)
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return nil, err
+		}
+		if isWebVTT(segment) && (len(downloadFns) > 0 || resume) {
+			// Only the first segment of the output keeps its header.
+			fn = webvttCues(fn)
 		}
-		downloadFns = append(downloadFns, prepare(client, req))
+		downloadFns = append(downloadFns, fn)
+		numbers = append(numbers, segment.Number)
+		downloaded = append(downloaded, segment)
 	}
 
-	return &Merger{downloads: downloadFns}, nil
+	m := newMerger(ctx, downloadFns, numbers, o)
+	m.media, m.segments = media, downloaded
+	m.discontinuous = discontinuous
+	m.muted = mutedRanges(media, segments)
+	m.position = media.Elapsed
+	for _, segment := range media.Segments {
+		if len(segments) == 0 || segment.Number >= segments[0].Number {
+			break
+		}
+		m.position += segment.Duration
+	}
+	return m, nil
 }
 
-func sliceSegments(segments []m3u8.MediaSegment, start, end time.Duration) ([]m3u8.MediaSegment, error) {
+// sliceSegments returns the segments of media between start and end.
+// The position of the segments inside the VOD starts at media.Elapsed.
+func sliceSegments(media m3u8.MediaPlaylist, start, end time.Duration) ([]m3u8.MediaSegment, error) {
+	segments := media.Segments
 	if start < 0 || end < 0 {
 		return nil, errors.New("Negative timestamps are not allowed")
 	}
@@ -163,7 +232,7 @@ func sliceSegments(segments []m3u8.MediaSegment, start, end time.Duration) ([]m3
 		end = time.Duration(math.MaxInt64)
 	}
 	slice := []m3u8.MediaSegment{}
-	segmentStart := time.Duration(0)
+	segmentStart := media.Elapsed
 	for _, segment := range segments {
 		segmentEnd := segmentStart + segment.Duration
 		if segmentEnd <= start {
@@ -177,9 +246,9 @@ func sliceSegments(segments []m3u8.MediaSegment, start, end time.Duration) ([]m3
 		segmentStart += segment.Duration
 	}
 	if len(slice) == 0 {
-		var dur time.Duration
-		for _, segment := range segments {
-			dur += segment.Duration
+		dur := media.Total
+		if dur == 0 {
+			dur = segmentStart
 		}
 		return nil, fmt.Errorf("Timestamps are not a subset of the video (video duration is %v)", dur)
 	}
@@ -189,63 +258,357 @@ func sliceSegments(segments []m3u8.MediaSegment, start, end time.Duration) ([]m3
 // downloadFunc describes a func that peform an HTTP request and returns the response.Body
 type downloadFunc func() (io.ReadCloser, error)
 
-func prepare(client *http.Client, req *http.Request) downloadFunc {
+// prepare returns a downloadFunc that performs req and retries it according to policy.
+// The response body is read entirely before being returned so that truncated bodies
+// can be retried.
+func prepare(client *http.Client, req *http.Request, policy RetryPolicy) downloadFunc {
 	return func() (io.ReadCloser, error) {
-		resp, err := client.Do(req)
-		if err != nil {
+		for attempt := 1; ; attempt++ {
+			body, err := fetch(client, req)
+			if err == nil {
+				return body, nil
+			}
+			if attempt >= policy.MaxAttempts || !policy.retryable(err) {
+				return nil, err
+			}
+			delay := policy.backoff(attempt)
+			if statusErr, ok := errors.Cause(err).(*StatusError); ok && statusErr.RetryAfter > delay {
+				delay = statusErr.RetryAfter
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+	}
+}
+
+func fetch(client *http.Client, req *http.Request) (io.ReadCloser, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	if s := resp.StatusCode; s < 200 || s >= 300 {
+		return nil, errors.WithStack(newStatusError(resp))
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if r := req.Header.Get("Range"); len(r) > 0 && resp.StatusCode == http.StatusOK {
+		// The server ignored the byte range and sent the whole resource.
+		var first, last int
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &first, &last); err != nil {
 			return nil, errors.WithStack(err)
 		}
-		if s := resp.StatusCode; s < 200 || s >= 300 {
-			return nil, errors.Errorf("%d: %s", s, req.URL)
+		if last >= len(b) {
+			return nil, errors.Errorf("byte range %s is outside of %s", r, req.URL)
 		}
-		return resp.Body, nil
+		b = b[first : last+1]
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// rangeRequest returns the request of the resource at URL restricted to r if not nil.
+func rangeRequest(ctx context.Context, URL string, r *m3u8.ByteRange) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, URL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if r != nil {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1))
+	}
+	return req.WithContext(ctx), nil
+}
+
+// segmentDownload returns the downloadFunc of segment, preceded by
+// its Media Initialization Section if withMap is true.
+func segmentDownload(ctx context.Context, client *http.Client, segment m3u8.MediaSegment, withMap bool, policy RetryPolicy) (downloadFunc, error) {
+	if segment.Key != nil {
+		return nil, errors.Errorf("segment %d is encrypted using %s which is not supported", segment.Number, segment.Key.Method)
+	}
+	req, err := rangeRequest(ctx, segment.URL, segment.ByteRange)
+	if err != nil {
+		return nil, err
+	}
+	fn := prepare(client, req, policy)
+	if !withMap || segment.Map == nil {
+		return fn, nil
+	}
+	mapReq, err := rangeRequest(ctx, segment.Map.URI, segment.Map.ByteRange)
+	if err != nil {
+		return nil, err
+	}
+	return concatDownloads(prepare(client, mapReq, policy), fn), nil
+}
+
+// sameMap reports whether a and b are the same Media Initialization Section.
+func sameMap(a, b *m3u8.Map) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.URI != b.URI || (a.ByteRange == nil) != (b.ByteRange == nil) {
+		return false
+	}
+	return a.ByteRange == nil || *a.ByteRange == *b.ByteRange
+}
+
+// concatDownloads returns a downloadFunc reading the bodies of fns one after another.
+func concatDownloads(fns ...downloadFunc) downloadFunc {
+	return func() (io.ReadCloser, error) {
+		var buf bytes.Buffer
+		for _, fn := range fns {
+			body, err := fn()
+			if err != nil {
+				return nil, err
+			}
+			_, err = buf.ReadFrom(body)
+			body.Close()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		return ioutil.NopCloser(&buf), nil
 	}
 }
 
 // Merger merges the "downloads" into a single io.Reader.
+// When concurrency is greater than 1, up to concurrency downloads are
+// fetched ahead of the reader into memory buffers and are still read back in order.
 type Merger struct {
-	downloads []downloadFunc
+	ctx         context.Context
+	downloads   []downloadFunc
+	numbers     []int
+	concurrency int
+	journal     *Journal
+	offset      int64
+	// position is the position in the VOD of the first segment.
+	position time.Duration
+	// media is the playlist of the VOD and segments holds the segment of
+	// each download. They are empty for clips.
+	media    m3u8.MediaPlaylist
+	segments []m3u8.MediaSegment
+	// muted holds the muted ranges of the VOD between start and end.
+	muted []Range
+	// discontinuous is true if the timestamps may jump between two downloads.
+	discontinuous bool
 
 	index   int
 	current io.ReadCloser
 	err     error
+
+	once    sync.Once
+	results chan chan fetched
+	done    chan struct{}
+	closed  bool
+}
+
+var errMergerClosed = errors.New("merger closed")
+
+// fetched holds a fully buffered download.
+type fetched struct {
+	body io.ReadCloser
+	err  error
+}
+
+// newMerger returns a Merger of downloads.
+// numbers holds the segment number of each download and is used to fill the journal.
+func newMerger(ctx context.Context, downloads []downloadFunc, numbers []int, o options) *Merger {
+	m := &Merger{ctx: ctx, downloads: downloads, numbers: numbers, concurrency: o.concurrency, journal: o.journal}
+	if last, ok := o.journal.Last(); ok {
+		m.offset = last.Offset
+	}
+	return m
+}
+
+// start launches the goroutine that schedules the parallel downloads.
+// The capacity of results bounds the number of buffered downloads.
+func (r *Merger) start() {
+	r.results = make(chan chan fetched, r.concurrency)
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.results)
+		for _, fn := range r.downloads {
+			ch := make(chan fetched, 1)
+			select {
+			case r.results <- ch:
+			case <-r.done:
+				return
+			case <-r.ctx.Done():
+				return
+			}
+			go func(fn downloadFunc) {
+				ch <- buffer(fn)
+			}(fn)
+		}
+	}()
+}
+
+// buffer performs the download and reads the whole body into memory.
+func buffer(fn downloadFunc) fetched {
+	body, err := fn()
+	if err != nil {
+		return fetched{err: err}
+	}
+	defer body.Close()
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return fetched{err: errors.WithStack(err)}
+	}
+	return fetched{body: ioutil.NopCloser(bytes.NewReader(b))}
 }
 
 func (r *Merger) next() error {
 	if r.index >= len(r.downloads) {
 		r.current = nil
-		r.index++
 		return nil
 	}
 	var err error
-	r.current, err = r.downloads[r.index]()
+	if r.concurrency > 1 {
+		r.once.Do(r.start)
+		var ch chan fetched
+		var ok bool
+		select {
+		case ch, ok = <-r.results:
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+		if !ok {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			return errMergerClosed
+		}
+		var f fetched
+		select {
+		case f = <-ch:
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		}
+		r.current, err = f.body, f.err
+	} else {
+		r.current, err = r.downloads[r.index]()
+	}
 	r.index++
 	return err
 }
 
 // Read allows Merger to implement io.Reader.
+// Read returns ctx.Err() once the context used to create the Merger is done.
 func (r *Merger) Read(p []byte) (int, error) {
 	for {
 		if r.err != nil {
 			return 0, r.err
 		}
+		if err := r.ctx.Err(); err != nil {
+			r.err = err
+			r.Close()
+			return 0, err
+		}
 		if r.current != nil {
 			n, err := r.current.Read(p)
+			r.offset += int64(n)
+			if err == io.EOF && n > 0 {
+				// Report the end of the download on the next call,
+				// once the caller has consumed p.
+				return n, nil
+			}
 			if err == io.EOF {
 				err = r.current.Close()
 				r.current = nil
+				if err == nil {
+					err = r.record()
+				}
 			}
 			return n, errors.WithStack(err)
 		}
 		if err := r.next(); err != nil {
+			if ctxErr := r.ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			r.err = err
+			r.Close()
 			return 0, err
 		}
 		if r.current == nil {
+			r.err = io.EOF
+			r.Close()
 			return 0, io.EOF
 		}
 	}
 }
 
+// chunk reads the next download entirely. It returns io.EOF once every
+// download has been read. It must not be mixed with calls to Read and
+// does not fill the journal, whose offsets refer to a single output.
+func (r *Merger) chunk() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	err := r.ctx.Err()
+	if err == nil {
+		err = r.next()
+	}
+	if err != nil {
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		r.err = err
+		r.Close()
+		return nil, err
+	}
+	if r.current == nil {
+		r.err = io.EOF
+		r.Close()
+		return nil, io.EOF
+	}
+	b, err := ioutil.ReadAll(r.current)
+	if closeErr := r.current.Close(); err == nil {
+		err = closeErr
+	}
+	r.current = nil
+	if err != nil {
+		r.err = errors.WithStack(err)
+		r.Close()
+		return nil, r.err
+	}
+	return b, nil
+}
+
+// record adds the download that has just been read entirely to the journal.
+// The bytes read from the previous calls to Read have already been written by
+// the caller at this point.
+func (r *Merger) record() error {
+	if r.journal == nil || r.index > len(r.numbers) {
+		return nil
+	}
+	return r.journal.Record(JournalEntry{Number: r.numbers[r.index-1], Offset: r.offset})
+}
+
+// Close stops the pending downloads.
+func (r *Merger) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.err == nil {
+		r.err = errMergerClosed
+	}
+	if r.done != nil {
+		close(r.done)
+	}
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return errors.WithStack(err)
+}
+
 // Chunks returns the number of chunks.
 func (r *Merger) Chunks() int {
 	return len(r.downloads)
@@ -255,3 +618,16 @@ func (r *Merger) Chunks() int {
 func (r *Merger) Current() int {
 	return r.index
 }
+
+// Position returns the position in the VOD of the first segment
+// between the start and end timestamps, i.e. the first chunk of a download
+// that is not resumed.
+func (r *Merger) Position() time.Duration {
+	return r.position
+}
+
+// Discontinuous reports whether the timestamps of the stream may jump between
+// two chunks, after a discontinuity of the playlist or around skipped chunks.
+func (r *Merger) Discontinuous() bool {
+	return r.discontinuous
+}
@@ -1,11 +1,19 @@
 package twitchdl
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -79,7 +87,7 @@ func TestSliceSegments(t *testing.T) {
 
 	for _, tc := range tcs {
 		t.Run(fmt.Sprintf("start: %v end: %v", tc.start, tc.end), func(t *testing.T) {
-			actual, err := sliceSegments(segments, tc.start, tc.end)
+			actual, err := sliceSegments(m3u8.MediaPlaylist{Segments: segments}, tc.start, tc.end)
 			if tc.expectedErr {
 				require.Error(t, err)
 				return
@@ -92,3 +100,170 @@ func TestSliceSegments(t *testing.T) {
 		})
 	}
 }
+
+func TestSliceSegments_Elapsed(t *testing.T) {
+	media := m3u8.MediaPlaylist{
+		Elapsed: time.Minute,
+		Total:   time.Minute + 15*time.Second,
+		Segments: []m3u8.MediaSegment{
+			{Number: 0, Duration: time.Second * 5},
+			{Number: 1, Duration: time.Second * 5},
+			{Number: 2, Duration: time.Second * 5},
+		},
+	}
+	actual, err := sliceSegments(media, time.Minute+6*time.Second, time.Minute+8*time.Second)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, 1, actual[0].Number)
+
+	_, err = sliceSegments(media, 0, 30*time.Second)
+	assert.EqualError(t, err, "Timestamps are not a subset of the video (video duration is 1m15s)")
+}
+
+func TestMerger(t *testing.T) {
+	var downloads []downloadFunc
+	var expected bytes.Buffer
+	for i := 0; i < 20; i++ {
+		b := bytes.Repeat([]byte{byte('a' + i)}, 1000+i)
+		expected.Write(b)
+		delay := time.Duration(20-i) * time.Millisecond
+		downloads = append(downloads, func() (io.ReadCloser, error) {
+			time.Sleep(delay)
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		})
+	}
+
+	for _, concurrency := range []int{1, 4, 30} {
+		t.Run(fmt.Sprintf("concurrency: %d", concurrency), func(t *testing.T) {
+			m := newMerger(context.Background(), downloads, nil, newOptions([]Option{WithConcurrency(concurrency)}))
+			actual, err := ioutil.ReadAll(m)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Bytes(), actual)
+			assert.Equal(t, m.Chunks(), m.Current())
+		})
+	}
+}
+
+func TestMerger_Error(t *testing.T) {
+	downloads := []downloadFunc{
+		func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("a")), nil },
+		func() (io.ReadCloser, error) { return nil, errors.New("failed") },
+		func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("c")), nil },
+	}
+	m := newMerger(context.Background(), downloads, nil, newOptions([]Option{WithConcurrency(2)}))
+	actual, err := ioutil.ReadAll(m)
+	require.Error(t, err)
+	assert.Equal(t, "a", string(actual))
+	assert.Equal(t, 2, m.Current())
+}
+
+func TestMerger_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	downloads := []downloadFunc{
+		func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("a")), nil },
+		func() (io.ReadCloser, error) {
+			cancel()
+			return ioutil.NopCloser(strings.NewReader("b")), nil
+		},
+		func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader("c")), nil },
+	}
+	m := newMerger(ctx, downloads, nil, newOptions(nil))
+	_, err := ioutil.ReadAll(m)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestNewDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/media.mp4":
+			http.ServeContent(w, r, "media.mp4", time.Time{}, strings.NewReader("0123456789"))
+		case "/ignored.mp4":
+			// Ignores the Range header.
+			fmt.Fprint(w, "abcdefghij")
+		default:
+			fmt.Fprintf(w, "[%s]", strings.TrimPrefix(r.URL.Path, "/"))
+		}
+	}))
+	defer srv.Close()
+
+	playlist := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:10.000,
+#EXT-X-BYTERANGE:4@0
+media.mp4
+#EXTINF:10.000,
+#EXT-X-BYTERANGE:6
+media.mp4
+#EXT-X-DISCONTINUITY
+#EXT-X-MAP:URI="init2.mp4"
+#EXTINF:10.000,
+#EXT-X-BYTERANGE:3@2
+ignored.mp4
+#EXT-X-GAP
+#EXTINF:10.000,
+gap.mp4
+#EXTINF:10.000,
+last.mp4
+#EXT-X-ENDLIST`
+	media, err := m3u8.Media(strings.NewReader(playlist), srv.URL+"/index.m3u8")
+	require.NoError(t, err)
+
+	m, err := newDownload(context.Background(), srv.Client(), media, 0, 0, newOptions(nil))
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(m)
+	require.NoError(t, err)
+	assert.Equal(t, "[init.mp4]0123456789[init2.mp4]cde[last.mp4]", string(b))
+	assert.Equal(t, 4, m.Chunks())
+	assert.True(t, m.Discontinuous())
+
+	encrypted := media
+	encrypted.Segments = append([]m3u8.MediaSegment{}, media.Segments...)
+	encrypted.Segments[0].Key = &m3u8.Key{Method: "AES-128"}
+	_, err = newDownload(context.Background(), srv.Client(), encrypted, 0, 0, newOptions(nil))
+	assert.Error(t, err)
+}
+
+func TestNewDownload_Empty(t *testing.T) {
+	media, err := m3u8.Media(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:10\n"), "https://example.com/index.m3u8")
+	require.NoError(t, err)
+	media.Elapsed = time.Minute
+
+	m, err := newDownload(context.Background(), http.DefaultClient, media, 0, 0, newOptions(nil))
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(m)
+	require.NoError(t, err)
+	assert.Empty(t, b)
+	assert.Equal(t, 0, m.Chunks())
+	assert.Equal(t, time.Minute, m.Position())
+	assert.False(t, m.Discontinuous())
+}
+
+func TestNewDownload_MapAfterGap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "[%s]", strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer srv.Close()
+
+	playlist := `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:10.000,
+a.mp4
+#EXT-X-DISCONTINUITY
+#EXT-X-MAP:URI="init2.mp4"
+#EXT-X-GAP
+#EXTINF:10.000,
+gap.mp4
+#EXTINF:10.000,
+b.mp4
+#EXT-X-ENDLIST`
+	media, err := m3u8.Media(strings.NewReader(playlist), srv.URL+"/index.m3u8")
+	require.NoError(t, err)
+
+	m, err := newDownload(context.Background(), srv.Client(), media, 0, 0, newOptions(nil))
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(m)
+	require.NoError(t, err)
+	assert.Equal(t, "[init.mp4][a.mp4][init2.mp4][b.mp4]", string(b))
+}
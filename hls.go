@@ -0,0 +1,186 @@
+package twitchdl
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/pkg/errors"
+)
+
+// hlsPlaylist is the name of the playlists written by HLSWriter and WriteHLSMaster.
+const hlsPlaylist = "index.m3u8"
+
+// HLSWriter writes the chunks of a VOD into a directory as individual files,
+// along with a media playlist "index.m3u8" referencing them with relative URLs.
+// The chunks are read from its Merger one by one by WriteNext.
+type HLSWriter struct {
+	dir string
+	m   *Merger
+
+	initURI  string
+	segments []m3u8.MediaSegment
+	err      error
+}
+
+// NewHLSWriter returns an HLSWriter writing the chunks read from m into dir.
+// m must be the download of a VOD.
+func NewHLSWriter(dir string, m *Merger) (*HLSWriter, error) {
+	if len(m.segments) != len(m.downloads) {
+		return nil, errors.New("HLS output requires the download of a VOD")
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &HLSWriter{dir: dir, m: m}, nil
+}
+
+// WriteNext reads the next chunk of the Merger and writes it to its own file.
+// It returns the size of the chunk, or io.EOF once every chunk has been written.
+func (h *HLSWriter) WriteNext() (int, error) {
+	if h.err != nil {
+		return 0, h.err
+	}
+	data, err := h.m.chunk()
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err == nil {
+		err = h.write(h.m.segments[h.m.Current()-1], data)
+	}
+	if err != nil {
+		h.err = err
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Close writes the media playlist of the chunks written by WriteNext.
+func (h *HLSWriter) Close() error {
+	if h.err != nil {
+		return h.err
+	}
+	h.err = errors.New("HLSWriter closed")
+	playlist := m3u8.MediaPlaylist{
+		Version:        h.m.media.Version,
+		TargetDuration: h.m.media.TargetDuration,
+		Type:           "VOD",
+		Ended:          true,
+		Segments:       h.segments,
+	}
+	if len(h.segments) > 0 {
+		playlist.Sequence = h.segments[0].Number
+	}
+	for _, s := range h.segments {
+		if s.Duration > playlist.TargetDuration {
+			playlist.TargetDuration = s.Duration
+		}
+	}
+	var b bytes.Buffer
+	if err := playlist.Encode(&b); err != nil {
+		return err
+	}
+	return errors.WithStack(ioutil.WriteFile(filepath.Join(h.dir, hlsPlaylist), b.Bytes(), 0666))
+}
+
+// write writes data, the chunk of segment, to its own file.
+func (h *HLSWriter) write(segment m3u8.MediaSegment, data []byte) error {
+	out := m3u8.MediaSegment{
+		Number:          segment.Number,
+		Duration:        segment.Duration,
+		Title:           segment.Title,
+		ProgramDateTime: segment.ProgramDateTime,
+		Discontinuity:   segment.Discontinuity,
+	}
+	if n := len(h.segments); n > 0 && h.segments[n-1].Number+1 != segment.Number {
+		// Segments are missing between the two segments.
+		out.Discontinuity = true
+	}
+	if segment.Map != nil {
+		// The Media Initialization Section is prepended to the chunk when it changes.
+		if n := initLength(data); n > 0 {
+			h.initURI = fmt.Sprintf("init-%d.mp4", segment.Number)
+			if err := ioutil.WriteFile(filepath.Join(h.dir, h.initURI), data[:n], 0666); err != nil {
+				return errors.WithStack(err)
+			}
+			data = data[n:]
+		}
+		if len(h.initURI) > 0 {
+			out.Map = &m3u8.Map{URI: h.initURI}
+		}
+	}
+
+	ext := ".ts"
+	if u, err := url.Parse(segment.URL); err == nil && len(path.Ext(u.Path)) > 0 {
+		ext = path.Ext(u.Path)
+	}
+	out.URL = fmt.Sprintf("%d%s", segment.Number, ext)
+	if err := ioutil.WriteFile(filepath.Join(h.dir, out.URL), data, 0666); err != nil {
+		return errors.WithStack(err)
+	}
+	h.segments = append(h.segments, out)
+	return nil
+}
+
+// initLength returns the length of the ftyp and moov boxes at the start of b.
+func initLength(b []byte) int {
+	n := 0
+	for len(b)-n >= 8 {
+		size := int(binary.BigEndian.Uint32(b[n:]))
+		typ := string(b[n+4 : n+8])
+		if (typ != "ftyp" && typ != "moov") || size < 8 || n+size > len(b) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// WriteHLSMaster writes into dir the master playlist "index.m3u8" of the VOD
+// "vodID" referencing the media playlists "<quality>/index.m3u8" of qualities.
+func WriteHLSMaster(ctx context.Context, client *http.Client, clientID, vodID string, qualities []Quality, dir string, opts ...Option) error {
+	api := newAPI(client, clientID, newOptions(opts))
+	m3u8raw, err := api.M3U8(ctx, vodID)
+	if err != nil {
+		return err
+	}
+	master, err := m3u8.Master(bytes.NewReader(m3u8raw))
+	if err != nil {
+		return err
+	}
+	var local m3u8.MasterPlaylist
+	for _, quality := range qualities {
+		variant, err := findVariant(master, quality)
+		if err != nil {
+			return err
+		}
+		var alternatives []m3u8.Alternative
+		for _, alt := range variant.Alternatives {
+			if alt.Name == quality.Name {
+				alternatives = append(alternatives, alt)
+			}
+		}
+		variant.Alternatives = alternatives
+		// The renditions of the groups are not downloaded.
+		variant.Subtitles, variant.ClosedCaptions = "", ""
+		variant.URL = url.PathEscape(quality.Name) + "/" + hlsPlaylist
+		local.Variants = append(local.Variants, variant)
+	}
+	var b bytes.Buffer
+	if err := local.Encode(&b); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(ioutil.WriteFile(filepath.Join(dir, hlsPlaylist), b.Bytes(), 0666))
+}
@@ -0,0 +1,128 @@
+package twitchdl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLSWriter(t *testing.T) {
+	vod := &fakeVOD{requests: map[string]int{}}
+	srv := httptest.NewServer(vod)
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "hls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ranges := []Range{{0, 20 * time.Second}, {50 * time.Second, 60 * time.Second}}
+	mergers, err := DownloadRanges(context.Background(), testClient(t, srv), "id", "1", Quality{Name: "1080p"}, ranges, WithConcurrency(3))
+	require.NoError(t, err)
+	m := Concat(mergers...)
+	h, err := NewHLSWriter(filepath.Join(dir, "1080p"), m)
+	require.NoError(t, err)
+	writeHLS(t, h)
+
+	for _, n := range []string{"0", "1", "5"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "1080p", n+".ts"))
+		require.NoError(t, err)
+		assert.Equal(t, "["+n+"]", string(b))
+	}
+	f, err := os.Open(filepath.Join(dir, "1080p", "index.m3u8"))
+	require.NoError(t, err)
+	defer f.Close()
+	media, err := m3u8.Media(f, "")
+	require.NoError(t, err)
+	assert.True(t, media.Ended)
+	var urls []string
+	var discontinuities []bool
+	for _, s := range media.Segments {
+		urls = append(urls, s.URL)
+		discontinuities = append(discontinuities, s.Discontinuity)
+	}
+	assert.Equal(t, []string{"0.ts", "1.ts", "5.ts"}, urls)
+	assert.Equal(t, []bool{false, false, true}, discontinuities)
+
+	require.NoError(t, WriteHLSMaster(context.Background(), testClient(t, srv), "id", "1", []Quality{{Name: "1080p"}}, dir))
+	f, err = os.Open(filepath.Join(dir, "index.m3u8"))
+	require.NoError(t, err)
+	defer f.Close()
+	master, err := m3u8.Master(f)
+	require.NoError(t, err)
+	require.Len(t, master.Variants, 1)
+	assert.Equal(t, "1080p/index.m3u8", master.Variants[0].URL)
+}
+
+// writeHLS writes every chunk of h and closes it.
+func writeHLS(t *testing.T, h *HLSWriter) {
+	for {
+		_, err := h.WriteNext()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.NoError(t, h.Close())
+}
+
+func TestHLSWriter_EmptyChunk(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1.ts" {
+			fmt.Fprintf(w, "[%s]", strings.TrimPrefix(r.URL.Path, "/"))
+		}
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "hls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	media, err := m3u8.Media(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:10\n"+
+		"#EXTINF:10.000,\n0.ts\n#EXTINF:10.000,\n1.ts\n#EXTINF:10.000,\n2.ts\n#EXT-X-ENDLIST\n"), srv.URL+"/index.m3u8")
+	require.NoError(t, err)
+	m, err := newDownload(context.Background(), srv.Client(), media, 0, 0, newOptions([]Option{WithConcurrency(2)}))
+	require.NoError(t, err)
+	h, err := NewHLSWriter(dir, m)
+	require.NoError(t, err)
+	writeHLS(t, h)
+
+	for n, expected := range []string{"[0.ts]", "", "[2.ts]"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.ts", n)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+	f, err := os.Open(filepath.Join(dir, "index.m3u8"))
+	require.NoError(t, err)
+	defer f.Close()
+	playlist, err := m3u8.Media(f, "")
+	require.NoError(t, err)
+	require.Len(t, playlist.Segments, 3)
+	for _, s := range playlist.Segments {
+		assert.False(t, s.Discontinuity)
+	}
+}
+
+func TestInitLength(t *testing.T) {
+	box := func(typ string, size int) []byte {
+		b := make([]byte, size)
+		b[3] = byte(size)
+		copy(b[4:], typ)
+		return b
+	}
+	var b []byte
+	b = append(b, box("ftyp", 16)...)
+	b = append(b, box("moov", 32)...)
+	b = append(b, box("moof", 24)...)
+	assert.Equal(t, 48, initLength(b))
+	assert.Equal(t, 0, initLength(box("moof", 24)))
+}
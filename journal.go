@@ -0,0 +1,155 @@
+package twitchdl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JournalEntry describes a chunk that has been entirely written to the output.
+type JournalEntry struct {
+	// Number is the m3u8.MediaSegment.Number of the chunk.
+	Number int
+	// Offset is the size of the output once the chunk has been written.
+	Offset int64
+}
+
+// Journal records the chunks already written to an output so that an
+// interrupted download can be resumed.
+//
+// The journal is a text file starting with a header identifying the download
+// followed by one "number offset" line per chunk.
+type Journal struct {
+	f       *os.File
+	key     string
+	entries []JournalEntry
+	closed  bool
+}
+
+// OpenJournal opens or creates the journal at path.
+// key identifies the download; an existing journal created with another key is rejected.
+func OpenJournal(path, key string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	j := &Journal{f: f, key: key}
+	if err := j.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) load() error {
+	scanner := bufio.NewScanner(j.f)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		return j.rewrite()
+	}
+	if header := scanner.Text(); header != j.header() {
+		return errors.Errorf("journal %s belongs to another download: %s", j.f.Name(), header)
+	}
+	for scanner.Scan() {
+		var e JournalEntry
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d", &e.Number, &e.Offset); err != nil {
+			// A partially written line ends the journal.
+			break
+		}
+		j.entries = append(j.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	return j.rewrite()
+}
+
+func (j *Journal) header() string {
+	return "twitchdl " + strings.Replace(j.key, "\n", " ", -1)
+}
+
+// rewrite writes the header and the current entries to the journal file.
+func (j *Journal) rewrite() error {
+	if err := j.f.Truncate(0); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := j.f.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	w := bufio.NewWriter(j.f)
+	fmt.Fprintln(w, j.header())
+	for _, e := range j.entries {
+		fmt.Fprintf(w, "%d %d\n", e.Number, e.Offset)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(j.f.Sync())
+}
+
+// Last returns the last chunk recorded. ok is false if the journal is empty.
+func (j *Journal) Last() (e JournalEntry, ok bool) {
+	if j == nil || len(j.entries) == 0 {
+		return JournalEntry{}, false
+	}
+	return j.entries[len(j.entries)-1], true
+}
+
+// Truncate checks the partial output against the journal.
+// The chunks beyond the size of output are dropped from the journal and
+// output is truncated right after the last recorded chunk.
+// The offset of output is set to its end.
+func (j *Journal) Truncate(output *os.File) error {
+	info, err := output.Stat()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	n := len(j.entries)
+	for n > 0 && j.entries[n-1].Offset > info.Size() {
+		n--
+	}
+	j.entries = j.entries[:n]
+	var offset int64
+	if last, ok := j.Last(); ok {
+		offset = last.Offset
+	}
+	if err := output.Truncate(offset); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := output.Seek(offset, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	return j.rewrite()
+}
+
+// Record appends a chunk to the journal.
+func (j *Journal) Record(e JournalEntry) error {
+	if _, err := fmt.Fprintf(j.f, "%d %d\n", e.Number, e.Offset); err != nil {
+		return errors.WithStack(err)
+	}
+	j.entries = append(j.entries, e)
+	return nil
+}
+
+// Close closes the journal file. Closing a closed journal does nothing.
+func (j *Journal) Close() error {
+	if j.closed {
+		return nil
+	}
+	j.closed = true
+	return errors.WithStack(j.f.Close())
+}
+
+// Remove closes and deletes the journal file once the download is complete.
+func (j *Journal) Remove() error {
+	if err := j.Close(); err != nil {
+		return err
+	}
+	return errors.WithStack(os.Remove(j.f.Name()))
+}
@@ -0,0 +1,93 @@
+package twitchdl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchdl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output, err := os.Create(filepath.Join(dir, "out.mp4"))
+	require.NoError(t, err)
+	defer output.Close()
+
+	journal, err := OpenJournal(filepath.Join(dir, "out.mp4.journal"), "key")
+	require.NoError(t, err)
+	_, ok := journal.Last()
+	assert.False(t, ok)
+
+	var downloads []downloadFunc
+	for _, chunk := range []string{"aaa", "bb", "cccc"} {
+		chunk := chunk
+		downloads = append(downloads, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader([]byte(chunk))), nil
+		})
+	}
+	m := newMerger(context.Background(), downloads[:2], []int{10, 11}, newOptions([]Option{WithJournal(journal)}))
+	_, err = io.Copy(output, m)
+	require.NoError(t, err)
+	// Simulate a partially written third chunk.
+	_, err = output.Write([]byte("cc"))
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	_, err = OpenJournal(filepath.Join(dir, "out.mp4.journal"), "other")
+	require.Error(t, err)
+
+	journal, err = OpenJournal(filepath.Join(dir, "out.mp4.journal"), "key")
+	require.NoError(t, err)
+	last, ok := journal.Last()
+	require.True(t, ok)
+	assert.Equal(t, JournalEntry{Number: 11, Offset: 5}, last)
+
+	require.NoError(t, journal.Truncate(output))
+	m = newMerger(context.Background(), downloads[2:], []int{12}, newOptions([]Option{WithJournal(journal)}))
+	_, err = io.Copy(output, m)
+	require.NoError(t, err)
+	last, _ = journal.Last()
+	assert.Equal(t, JournalEntry{Number: 12, Offset: 9}, last)
+
+	b, err := ioutil.ReadFile(output.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "aaabbcccc", string(b))
+	require.NoError(t, journal.Remove())
+	// A deferred Close following Remove does nothing.
+	assert.NoError(t, journal.Close())
+}
+
+func TestJournal_TruncateMissingData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twitchdl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output, err := os.Create(filepath.Join(dir, "out.mp4"))
+	require.NoError(t, err)
+	defer output.Close()
+	_, err = output.Write([]byte("aaab"))
+	require.NoError(t, err)
+
+	journal, err := OpenJournal(filepath.Join(dir, "out.mp4.journal"), "key")
+	require.NoError(t, err)
+	defer journal.Close()
+	require.NoError(t, journal.Record(JournalEntry{Number: 0, Offset: 3}))
+	require.NoError(t, journal.Record(JournalEntry{Number: 1, Offset: 5}))
+
+	require.NoError(t, journal.Truncate(output))
+	last, ok := journal.Last()
+	require.True(t, ok)
+	assert.Equal(t, JournalEntry{Number: 0, Offset: 3}, last)
+	info, err := output.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Size())
+}
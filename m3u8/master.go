@@ -33,6 +33,11 @@ type Alternative struct {
 	// Optional
 	Autoselect bool
 	Default    bool
+	// URL is the media playlist of the rendition. It is empty for
+	// closed captions which are carried inside the video stream.
+	URL        string
+	Language   string
+	InstreamID string
 }
 
 // Variant specifies a Variant Stream.
@@ -43,11 +48,14 @@ type Variant struct {
 	URL       string
 	Bandwidth int
 	// Optional
-	Codecs       []string
-	Resolution   Resolution
-	Video        string
-	Audio        string
-	Alternatives []Alternative
+	Codecs         []string
+	Resolution     Resolution
+	FrameRate      float64
+	Video          string
+	Audio          string
+	Subtitles      string
+	ClosedCaptions string
+	Alternatives   []Alternative
 }
 
 // MasterPlaylist defines the Variant Streams, Renditions, and
@@ -58,29 +66,14 @@ type MasterPlaylist struct {
 	Variants []Variant
 }
 
-func attributes(line string) (map[string]string, error) {
-	insideQuotes := false
-	fn := func(c rune) bool {
-		if '"' == c {
-			insideQuotes = !insideQuotes
-			return false
-		}
-		return ',' == c && !insideQuotes
-	}
-	list := strings.FieldsFunc(line, fn)
-	attr := map[string]string{}
-	for _, it := range list {
-		kv := strings.Split(it, "=")
-		if len(kv) != 2 {
-			return attr, errors.New("malformed attribute")
-		}
-		attr[kv[0]] = strings.Trim(kv[1], `"`)
-	}
-	return attr, nil
-}
-
 // Master parses a Master Playlist.
+// The first malformed line is returned as a *ParseError.
 func Master(r io.Reader) (MasterPlaylist, error) {
+	return (&Decoder{}).Master(r)
+}
+
+// Master parses a Master Playlist like Master does.
+func (d *Decoder) Master(r io.Reader) (MasterPlaylist, error) {
 	scanner := bufio.NewScanner(r)
 
 	if !scanner.Scan() {
@@ -88,54 +81,64 @@ func Master(r io.Reader) (MasterPlaylist, error) {
 	}
 	sig := scanner.Text()
 	if sig != "#EXTM3U" {
-		return MasterPlaylist{}, errors.New("invalid signature")
+		return MasterPlaylist{}, &ParseError{Line: 1, Err: errors.New("invalid signature")}
 	}
 
 	playlist := MasterPlaylist{}
-	alternatives := []Alternative{}
-	for scanner.Scan() {
-		line := scanner.Text()
-
+	type alternative struct {
+		Alternative
+		line int
+	}
+	alternatives := []alternative{}
+	// variant is the variant being parsed since the STREAM-INF tag at variantLine.
+	var variant *Variant
+	var variantLine int
+	parseLine := func(n int, line string) error {
 		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
 			attr, err := attributes(line[18:])
 			if err != nil {
-				return playlist, err
+				return err
 			}
-			variant := Variant{}
+			v := &Variant{}
 
 			bandwidth, ok := attr["BANDWIDTH"]
 			if ok {
 				bandwidth, err := strconv.Atoi(bandwidth)
 				if err != nil {
-					return playlist, errors.WithStack(err)
+					return errors.WithStack(err)
 				}
-				variant.Bandwidth = bandwidth
+				v.Bandwidth = bandwidth
 			}
 
 			codecs, _ := attr["CODECS"]
-			variant.Codecs = strings.Split(codecs, ",")
+			v.Codecs = strings.Split(codecs, ",")
 
 			resolution, _ := attr["RESOLUTION"]
-			fmt.Sscanf(resolution, "%dx%d", &variant.Resolution.Width, &variant.Resolution.Height)
+			fmt.Sscanf(resolution, "%dx%d", &v.Resolution.Width, &v.Resolution.Height)
 
-			variant.Video, _ = attr["VIDEO"]
-			variant.Audio, _ = attr["AUDIO"]
+			if frameRate, ok := attr["FRAME-RATE"]; ok {
+				if v.FrameRate, err = strconv.ParseFloat(frameRate, 64); err != nil {
+					return errors.WithStack(err)
+				}
+			}
 
-			if !scanner.Scan() {
-				return playlist, errors.WithStack(io.ErrUnexpectedEOF)
+			v.Video, _ = attr["VIDEO"]
+			v.Audio, _ = attr["AUDIO"]
+			v.Subtitles, _ = attr["SUBTITLES"]
+			if cc, _ := attr["CLOSED-CAPTIONS"]; cc != "NONE" {
+				v.ClosedCaptions = cc
 			}
-			variant.URL = scanner.Text()
 
-			playlist.Variants = append(playlist.Variants, variant)
-			continue
+			variant, variantLine = v, n
+			return nil
 		}
 
 		if strings.HasPrefix(line, "#EXT-X-MEDIA:") {
 			attr, err := attributes(line[13:])
 			if err != nil {
-				return playlist, err
+				return err
 			}
-			alternative := Alternative{}
+			alternative := alternative{line: n}
 
 			alternative.Type, _ = attr["TYPE"]
 			alternative.GroupID, _ = attr["GROUP-ID"]
@@ -147,13 +150,47 @@ func Master(r io.Reader) (MasterPlaylist, error) {
 			def, _ := attr["DEFAULT"]
 			alternative.Default = def == "YES"
 
+			alternative.URL, _ = attr["URI"]
+			alternative.Language, _ = attr["LANGUAGE"]
+			alternative.InstreamID, _ = attr["INSTREAM-ID"]
+
 			alternatives = append(alternatives, alternative)
-			continue
+			return nil
 		}
 
-		// Discard line.
+		if len(line) == 0 || strings.HasPrefix(line, "#") || variant == nil {
+			// Discard line.
+			return nil
+		}
+
+		// line is the URI of the variant.
+		variant.URL = line
+		playlist.Variants = append(playlist.Variants, *variant)
+		variant = nil
+		return nil
+	}
+
+	for n := 2; scanner.Scan(); n++ {
+		if variant != nil && strings.HasPrefix(scanner.Text(), "#EXT-X-STREAM-INF:") {
+			// The previous STREAM-INF tag is not followed by an URI.
+			if err := d.fail(variantLine, "#EXT-X-STREAM-INF", errors.New("missing URI")); err != nil {
+				return playlist, err
+			}
+			variant = nil
+		}
+		if err := parseLine(n, scanner.Text()); err != nil {
+			if err := d.fail(n, scanner.Text(), err); err != nil {
+				return playlist, err
+			}
+		}
 	}
 
+	if variant != nil {
+		// The last STREAM-INF tag is not followed by an URI.
+		if err := d.fail(variantLine, "#EXT-X-STREAM-INF", io.ErrUnexpectedEOF); err != nil {
+			return playlist, err
+		}
+	}
 	if err := scanner.Err(); err != nil {
 		return playlist, errors.WithStack(err)
 	}
@@ -161,21 +198,96 @@ func Master(r io.Reader) (MasterPlaylist, error) {
 	// Match alternatives with variants.
 	for _, alt := range alternatives {
 		var match func(v Variant, a Alternative) bool
+		// Subtitles and closed captions are shared by all the variants of their group.
+		shared := false
 		if alt.Type == "VIDEO" {
 			match = func(v Variant, a Alternative) bool { return a.GroupID == v.Video }
 		} else if alt.Type == "AUDIO" {
 			match = func(v Variant, a Alternative) bool { return a.GroupID == v.Audio }
+		} else if alt.Type == "SUBTITLES" {
+			match = func(v Variant, a Alternative) bool { return a.GroupID == v.Subtitles }
+			shared = true
+		} else if alt.Type == "CLOSED-CAPTIONS" {
+			match = func(v Variant, a Alternative) bool { return a.GroupID == v.ClosedCaptions }
+			shared = true
 		} else {
-			return playlist, errors.Errorf("unsupported alternative type %s", alt.Type)
+			err := d.fail(alt.line, "#EXT-X-MEDIA", errors.Errorf("unsupported alternative type %s", alt.Type))
+			if err != nil {
+				return playlist, err
+			}
+			continue
 		}
 
 		for i, v := range playlist.Variants {
-			if match(v, alt) {
-				playlist.Variants[i].Alternatives = append(playlist.Variants[i].Alternatives, alt)
-				break
+			if match(v, alt.Alternative) {
+				playlist.Variants[i].Alternatives = append(playlist.Variants[i].Alternatives, alt.Alternative)
+				if !shared {
+					break
+				}
 			}
 		}
 	}
 
 	return playlist, nil
 }
+
+// Encode writes the Master Playlist to w.
+// The alternatives shared by several variants are written once.
+func (p MasterPlaylist) Encode(w io.Writer) error {
+	var b encoder
+	b.WriteString("#EXTM3U\n")
+	written := map[Alternative]bool{}
+	for _, v := range p.Variants {
+		for _, alt := range v.Alternatives {
+			if written[alt] {
+				continue
+			}
+			written[alt] = true
+			fmt.Fprintf(&b, "#EXT-X-MEDIA:TYPE=%s,GROUP-ID=%s,NAME=%s", alt.Type, b.quote(alt.GroupID), b.quote(alt.Name))
+			if len(alt.Language) > 0 {
+				fmt.Fprintf(&b, ",LANGUAGE=%s", b.quote(alt.Language))
+			}
+			if alt.Autoselect {
+				b.WriteString(",AUTOSELECT=YES")
+			}
+			if alt.Default {
+				b.WriteString(",DEFAULT=YES")
+			}
+			if len(alt.InstreamID) > 0 {
+				fmt.Fprintf(&b, ",INSTREAM-ID=%s", b.quote(alt.InstreamID))
+			}
+			if len(alt.URL) > 0 {
+				fmt.Fprintf(&b, ",URI=%s", b.quote(alt.URL))
+			}
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d", v.Bandwidth)
+		if codecs := strings.Join(v.Codecs, ","); len(codecs) > 0 {
+			fmt.Fprintf(&b, ",CODECS=%s", b.quote(codecs))
+		}
+		if v.Resolution.Width > 0 && v.Resolution.Height > 0 {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", v.Resolution.Width, v.Resolution.Height)
+		}
+		if v.FrameRate > 0 {
+			fmt.Fprintf(&b, ",FRAME-RATE=%s", strconv.FormatFloat(v.FrameRate, 'f', 3, 64))
+		}
+		if len(v.Video) > 0 {
+			fmt.Fprintf(&b, ",VIDEO=%s", b.quote(v.Video))
+		}
+		if len(v.Audio) > 0 {
+			fmt.Fprintf(&b, ",AUDIO=%s", b.quote(v.Audio))
+		}
+		if len(v.Subtitles) > 0 {
+			fmt.Fprintf(&b, ",SUBTITLES=%s", b.quote(v.Subtitles))
+		}
+		if len(v.ClosedCaptions) > 0 {
+			fmt.Fprintf(&b, ",CLOSED-CAPTIONS=%s", b.quote(v.ClosedCaptions))
+		}
+		fmt.Fprintf(&b, "\n%s\n", v.URL)
+	}
+	if b.err != nil {
+		return b.err
+	}
+	_, err := w.Write(b.Bytes())
+	return errors.WithStack(err)
+}
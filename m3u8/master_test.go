@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/jybp/twitch-downloader/m3u8"
 )
@@ -58,3 +59,64 @@ http://example.com/720p30/index-dvr.m3u8`)
 	assert.False(t, playlist.Variants[1].Alternatives[0].Autoselect)
 	assert.False(t, playlist.Variants[1].Alternatives[0].Default)
 }
+
+func TestMasterEncode(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=6847192,CODECS="avc1.42C028,mp4a.40.2",RESOLUTION=1920x1080,FRAME-RATE=59.940,VIDEO="chunked"
+chunked/index-dvr.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="audio_only",NAME="Audio Only"
+#EXT-X-STREAM-INF:BANDWIDTH=160000,CODECS="mp4a.40.2",VIDEO="audio_only"
+audio_only/index-dvr.m3u8
+`)
+	playlist, err := m3u8.Master(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var encoded bytes.Buffer
+	if err := playlist.Encode(&encoded); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, string(b), encoded.String())
+
+	decoded, err := m3u8.Master(&encoded)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, playlist, decoded)
+}
+
+func TestMaster_Subtitles(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",AUTOSELECT=YES,DEFAULT=YES,URI="subs/en.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="Français",LANGUAGE="fr",URI="subs/fr.m3u8"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",LANGUAGE="en",INSTREAM-ID="CC1"
+#EXT-X-STREAM-INF:BANDWIDTH=6847192,SUBTITLES="subs",CLOSED-CAPTIONS="cc"
+1080p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2303475,SUBTITLES="subs",CLOSED-CAPTIONS="cc"
+720p/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=160000
+audio/index.m3u8
+`)
+	playlist, err := m3u8.Master(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	require.Len(t, playlist.Variants, 3)
+	english := m3u8.Alternative{Type: "SUBTITLES", GroupID: "subs", Name: "English", Language: "en",
+		Autoselect: true, Default: true, URL: "subs/en.m3u8"}
+	french := m3u8.Alternative{Type: "SUBTITLES", GroupID: "subs", Name: "Français", Language: "fr", URL: "subs/fr.m3u8"}
+	cc := m3u8.Alternative{Type: "CLOSED-CAPTIONS", GroupID: "cc", Name: "English", Language: "en", InstreamID: "CC1"}
+	for _, v := range playlist.Variants[:2] {
+		assert.Equal(t, "subs", v.Subtitles)
+		assert.Equal(t, "cc", v.ClosedCaptions)
+		assert.Equal(t, []m3u8.Alternative{english, french, cc}, v.Alternatives)
+	}
+	assert.Empty(t, playlist.Variants[2].Alternatives)
+
+	var encoded bytes.Buffer
+	if err := playlist.Encode(&encoded); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, string(b), encoded.String())
+}
@@ -2,9 +2,13 @@ package m3u8
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"net/url"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +23,101 @@ type MediaSegment struct {
 	Number   int
 	Duration time.Duration
 	URL      string
+	// Optional
+	Title string
+	// ByteRange is the sub-range of the resource at URL, if any.
+	ByteRange *ByteRange
+	// Discontinuity is true if the segment follows an EXT-X-DISCONTINUITY tag.
+	Discontinuity bool
+	// DiscontinuitySequence is the discontinuity sequence number of the segment.
+	DiscontinuitySequence int
+	// Key is the key used to encrypt the segment or nil if it is not encrypted.
+	Key *Key
+	// Map is the Media Initialization Section of the segment, if any.
+	Map *Map
+	// ProgramDateTime is the date of the first sample of the segment.
+	// It is zero if the playlist does not carry dates.
+	ProgramDateTime time.Time
+	// Gap is true if the segment is missing.
+	Gap bool
+	// Ad is true if the segment is an advertisement stitched by twitch
+	// into a live stream.
+	Ad bool
+	// Muted is true if twitch replaced the audio of the segment with silence.
+	Muted bool
+}
+
+// ByteRange is a sub-range of a resource.
+//
+// https://tools.ietf.org/html/rfc8216#section-4.3.2.2
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// Key describes how to decrypt segments.
+//
+// https://tools.ietf.org/html/rfc8216#section-4.3.2.4
+type Key struct {
+	// Required
+	Method string
+	// Optional
+	URI               string
+	IV                []byte
+	KeyFormat         string
+	KeyFormatVersions string
+}
+
+// Map describes the Media Initialization Section of segments.
+//
+// https://tools.ietf.org/html/rfc8216#section-4.3.2.5
+type Map struct {
+	// Required
+	URI string
+	// Optional
+	ByteRange *ByteRange
+}
+
+// DateRange associates attributes with a range of time.
+//
+// https://tools.ietf.org/html/rfc8216#section-4.3.2.7
+type DateRange struct {
+	// Required
+	ID        string
+	StartDate time.Time
+	// Optional
+	Class           string
+	EndDate         time.Time
+	Duration        time.Duration
+	PlannedDuration time.Duration
+	// Attributes holds the client-defined attributes prefixed by "X-".
+	Attributes map[string]AttributeValue
+}
+
+// AttributeValue is the value of a client-defined attribute.
+type AttributeValue struct {
+	Value string
+	// Quoted is true if Value is a quoted-string rather than a
+	// hexadecimal sequence or a decimal floating-point number.
+	Quoted bool
+}
+
+// End returns the end of the range or the zero time if it is unknown.
+func (d DateRange) End() time.Time {
+	switch {
+	case !d.EndDate.IsZero():
+		return d.EndDate
+	case d.Duration > 0:
+		return d.StartDate.Add(d.Duration)
+	case d.PlannedDuration > 0:
+		return d.StartDate.Add(d.PlannedDuration)
+	}
+	return time.Time{}
+}
+
+// Ad reports whether the range is an advertisement stitched by twitch.
+func (d DateRange) Ad() bool {
+	return d.Class == "twitch-stitched-ad" || strings.HasPrefix(d.ID, "stitched-ad-")
 }
 
 // MediaPlaylist contains a series of Media Segments that make up the
@@ -26,17 +125,108 @@ type MediaSegment struct {
 //
 // https://tools.ietf.org/html/rfc8216#page-22
 type MediaPlaylist struct {
-	TargetDuration time.Duration
-	Type           string
-	Sequence       int
-	Ended          bool
-	Segments       []MediaSegment
+	Version               int
+	TargetDuration        time.Duration
+	Type                  string
+	Sequence              int
+	DiscontinuitySequence int
+	Ended                 bool
+	Segments              []MediaSegment
+	DateRanges            []DateRange
+
+	// Twitch specific tags.
+
+	// Elapsed is the position of the first segment inside the VOD.
+	Elapsed time.Duration
+	// Total is the duration of the whole VOD.
+	Total time.Duration
+	// LiveSequence is the sequence number of the live stream.
+	LiveSequence int
+	// Prefetch holds the URLs of the upcoming segments of a live stream.
+	Prefetch []string
+}
+
+// byteRange parses a byte range formatted as "<n>[@<o>]".
+// The offset defaults to next if it is omitted.
+func byteRange(s string, next int64) (*ByteRange, error) {
+	r := &ByteRange{Offset: next}
+	length := s
+	if i := strings.Index(s, "@"); i >= 0 {
+		length = s[:i]
+		offset, err := strconv.ParseInt(s[i+1:], 10, 64)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		r.Offset = offset
+	}
+	n, err := strconv.ParseInt(length, 10, 64)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.Length = n
+	return r, nil
+}
+
+// seconds parses a decimal number of seconds.
+func seconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return time.Duration(f * float64(time.Second)), nil
+}
+
+// dateRange parses the attributes of an EXT-X-DATERANGE tag.
+// quoted reports which attributes are quoted strings.
+func dateRange(attr map[string]string, quoted map[string]bool) (DateRange, error) {
+	d := DateRange{ID: attr["ID"], Class: attr["CLASS"], Attributes: map[string]AttributeValue{}}
+	var err error
+	if d.StartDate, err = programDateTime(attr["START-DATE"]); err != nil {
+		return d, err
+	}
+	if v, ok := attr["END-DATE"]; ok {
+		if d.EndDate, err = programDateTime(v); err != nil {
+			return d, err
+		}
+	}
+	if v, ok := attr["DURATION"]; ok {
+		if d.Duration, err = seconds(v); err != nil {
+			return d, err
+		}
+	}
+	if v, ok := attr["PLANNED-DURATION"]; ok {
+		if d.PlannedDuration, err = seconds(v); err != nil {
+			return d, err
+		}
+	}
+	for k, v := range attr {
+		if strings.HasPrefix(k, "X-") {
+			d.Attributes[k] = AttributeValue{Value: v, Quoted: quoted[k]}
+		}
+	}
+	return d, nil
+}
+
+// programDateTime parses an ISO/IEC 8601:2004 date.
+func programDateTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid date %s", s)
 }
 
 // Media parses a Media Playlist.
 // URL is an optional argument that matches the Variant URL inside the Master Playlist.
 // It is used to construct full URLs if the URLs inside Media Segments are relative.
+// The first malformed line is returned as a *ParseError.
 func Media(r io.Reader, URL string) (MediaPlaylist, error) {
+	return (&Decoder{}).Media(r, URL)
+}
+
+// Media parses a Media Playlist like Media does.
+func (d *Decoder) Media(r io.Reader, URL string) (MediaPlaylist, error) {
 	var baseURL *url.URL
 	if len(URL) > 0 {
 		var err error
@@ -45,6 +235,19 @@ func Media(r io.Reader, URL string) (MediaPlaylist, error) {
 			return MediaPlaylist{}, errors.WithStack(err)
 		}
 	}
+	resolve := func(s string) (string, error) {
+		if baseURL == nil {
+			return s, nil
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if u.IsAbs() {
+			return s, nil
+		}
+		return baseURL.ResolveReference(u).String(), nil
+	}
 
 	scanner := bufio.NewScanner(r)
 
@@ -53,77 +256,442 @@ func Media(r io.Reader, URL string) (MediaPlaylist, error) {
 	}
 	sig := scanner.Text()
 	if sig != "#EXTM3U" {
-		return MediaPlaylist{}, errors.New("invalid signature")
+		return MediaPlaylist{}, &ParseError{Line: 1, Err: errors.New("invalid signature")}
 	}
 
 	playlist := MediaPlaylist{}
 	var segmentIndex = 0
-	for scanner.Scan() {
-		line := scanner.Text()
+	// segment is the segment being parsed since the EXTINF tag at segmentLine.
+	var segment *MediaSegment
+	var segmentLine int
+	// The following tags apply to the next segment.
+	var byterange *ByteRange
+	var discontinuity, gap bool
+	var date time.Time
+	// The following tags apply to all the next segments.
+	var key *Key
+	var initMap *Map
+	var discontinuities int
+	// nextOffset is the offset following the byte range of the previous segment.
+	var nextOffset int64
+	parseLine := func(n int, line string) error {
+		if strings.HasPrefix(line, "#EXT-X-VERSION:") {
+			n, err := strconv.Atoi(line[15:])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			playlist.Version = n
+			return nil
+		}
 
 		if strings.HasPrefix(line, "#EXT-X-TARGETDURATION:") {
 			d, err := strconv.Atoi(line[22:])
 			if err != nil {
-				return playlist, errors.WithStack(err)
+				return errors.WithStack(err)
 			}
 			playlist.TargetDuration = time.Second * time.Duration(d)
-			continue
+			return nil
 		}
 
 		if strings.HasPrefix(line, "#EXT-X-PLAYLIST-TYPE:") {
 			playlist.Type = line[21:]
-			continue
+			return nil
 		}
 
 		if strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:") {
 			n, err := strconv.Atoi(line[22:])
 			if err != nil {
-				return playlist, errors.WithStack(err)
+				return errors.WithStack(err)
 			}
 			playlist.Sequence = n
-			continue
+			return nil
 		}
 
-		if strings.HasPrefix(line, "#EXTINF:") {
-			segment := MediaSegment{}
-			segment.Number = playlist.Sequence + segmentIndex
-			segmentIndex++
-
-			firstComma := strings.Index(line, ",")
-			if firstComma == -1 {
-				firstComma = len(line)
+		if strings.HasPrefix(line, "#EXT-X-DISCONTINUITY-SEQUENCE:") {
+			n, err := strconv.Atoi(line[30:])
+			if err != nil {
+				return errors.WithStack(err)
 			}
-			d, err := strconv.ParseFloat(line[8:firstComma], 64)
+			playlist.DiscontinuitySequence = n
+			discontinuities = n
+			return nil
+		}
+
+		if line == "#EXT-X-DISCONTINUITY" {
+			discontinuity = true
+			discontinuities++
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-BYTERANGE:") {
+			r, err := byteRange(line[17:], nextOffset)
 			if err != nil {
-				return playlist, errors.WithStack(err)
+				return err
 			}
-			segment.Duration = time.Duration(d * float64(time.Second))
+			byterange = r
+			return nil
+		}
 
-			if !scanner.Scan() {
-				return playlist, errors.WithStack(io.ErrUnexpectedEOF)
+		if strings.HasPrefix(line, "#EXT-X-KEY:") {
+			attr, err := attributes(line[11:])
+			if err != nil {
+				return err
+			}
+			if attr["METHOD"] == "NONE" {
+				key = nil
+				return nil
 			}
-			segment.URL = scanner.Text()
-			if baseURL != nil {
-				segmentURL, err := url.Parse(segment.URL)
-				if err != nil {
-					return playlist, errors.WithStack(err)
+			k := &Key{
+				Method:            attr["METHOD"],
+				KeyFormat:         attr["KEYFORMAT"],
+				KeyFormatVersions: attr["KEYFORMATVERSIONS"],
+			}
+			if uri, ok := attr["URI"]; ok {
+				if k.URI, err = resolve(uri); err != nil {
+					return err
 				}
-				if !segmentURL.IsAbs() {
-					segment.URL = baseURL.ResolveReference(segmentURL).String()
+			}
+			if iv, ok := attr["IV"]; ok {
+				iv = strings.TrimPrefix(strings.TrimPrefix(iv, "0x"), "0X")
+				if k.IV, err = hex.DecodeString(iv); err != nil {
+					return errors.WithStack(err)
 				}
 			}
+			key = k
+			return nil
+		}
 
-			playlist.Segments = append(playlist.Segments, segment)
-			continue
+		if strings.HasPrefix(line, "#EXT-X-MAP:") {
+			attr, err := attributes(line[11:])
+			if err != nil {
+				return err
+			}
+			uri, ok := attr["URI"]
+			if !ok {
+				return errors.New("missing URI attribute")
+			}
+			m := &Map{}
+			if m.URI, err = resolve(uri); err != nil {
+				return err
+			}
+			if r, ok := attr["BYTERANGE"]; ok {
+				if m.ByteRange, err = byteRange(r, 0); err != nil {
+					return err
+				}
+			}
+			initMap = m
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:") {
+			t, err := programDateTime(line[25:])
+			if err != nil {
+				return err
+			}
+			date = t
+			return nil
+		}
+
+		if line == "#EXT-X-GAP" {
+			gap = true
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-DATERANGE:") {
+			attr, quoted, err := quotedAttributes(line[17:])
+			if err != nil {
+				return err
+			}
+			d, err := dateRange(attr, quoted)
+			if err != nil {
+				return err
+			}
+			playlist.DateRanges = append(playlist.DateRanges, d)
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-TWITCH-ELAPSED-SECS:") {
+			d, err := seconds(line[27:])
+			if err != nil {
+				return err
+			}
+			playlist.Elapsed = d
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-TWITCH-TOTAL-SECS:") {
+			d, err := seconds(line[25:])
+			if err != nil {
+				return err
+			}
+			playlist.Total = d
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-TWITCH-LIVE-SEQUENCE:") {
+			n, err := strconv.Atoi(line[28:])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			playlist.LiveSequence = n
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXT-X-TWITCH-PREFETCH:") {
+			u, err := resolve(line[23:])
+			if err != nil {
+				return err
+			}
+			playlist.Prefetch = append(playlist.Prefetch, u)
+			return nil
+		}
+
+		if strings.HasPrefix(line, "#EXTINF:") {
+			s := &MediaSegment{}
+			firstComma := strings.Index(line, ",")
+			if firstComma == -1 {
+				firstComma = len(line)
+			} else {
+				s.Title = line[firstComma+1:]
+			}
+			d, err := seconds(line[8:firstComma])
+			if err != nil {
+				return err
+			}
+			s.Duration = d
+			s.Number = playlist.Sequence + segmentIndex
+			segmentIndex++
+			segment, segmentLine = s, n
+			return nil
 		}
 
 		if line == "#EXT-X-ENDLIST" {
 			playlist.Ended = true
+			return nil
+		}
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") || segment == nil {
+			// Discard line.
+			return nil
+		}
+
+		// line is the URI of the segment.
+		var err error
+		if segment.URL, err = resolve(line); err != nil {
+			return err
+		}
+		segment.ByteRange = byterange
+		if byterange != nil {
+			nextOffset = byterange.Offset + byterange.Length
+		}
+		segment.Discontinuity = discontinuity
+		segment.DiscontinuitySequence = discontinuities
+		segment.Key = key
+		segment.Map = initMap
+		segment.Gap = gap
+		segment.ProgramDateTime = date
+		if n := len(playlist.Segments); date.IsZero() && n > 0 && !discontinuity {
+			// The date of a segment follows the date of the previous segment.
+			prev := playlist.Segments[n-1]
+			if !prev.ProgramDateTime.IsZero() {
+				segment.ProgramDateTime = prev.ProgramDateTime.Add(prev.Duration)
+			}
+		}
+		playlist.Segments = append(playlist.Segments, *segment)
+		segment, byterange, discontinuity, gap, date = nil, nil, false, false, time.Time{}
+		return nil
+	}
+
+	for n := 2; scanner.Scan(); n++ {
+		if err := parseLine(n, scanner.Text()); err != nil {
+			if err := d.fail(n, scanner.Text(), err); err != nil {
+				return playlist, err
+			}
+		}
+	}
+
+	if segment != nil {
+		// The last EXTINF tag is not followed by an URI.
+		if err := d.fail(segmentLine, "#EXTINF", io.ErrUnexpectedEOF); err != nil {
+			return playlist, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return playlist, errors.WithStack(err)
+	}
+
+	for i, segment := range playlist.Segments {
+		// Twitch titles the segments of the stream "live" and the ads "Amazon|...".
+		playlist.Segments[i].Ad = strings.HasPrefix(segment.Title, "Amazon")
+		// Twitch names the muted segments of a VOD "<n>-muted.ts".
+		playlist.Segments[i].Muted = mutedURL(segment.URL)
+		if segment.ProgramDateTime.IsZero() {
 			continue
 		}
+		for _, d := range playlist.DateRanges {
+			end := d.End()
+			if d.Ad() && !segment.ProgramDateTime.Before(d.StartDate) && (end.IsZero() || segment.ProgramDateTime.Before(end)) {
+				playlist.Segments[i].Ad = true
+			}
+		}
+	}
+	return playlist, nil
+}
+
+// mutedURL reports whether URL is the URL of a segment muted by twitch.
+func mutedURL(URL string) bool {
+	if u, err := url.Parse(URL); err == nil {
+		URL = u.Path
+	}
+	return strings.HasSuffix(URL, "-muted.ts")
+}
+
+// Encode writes the Media Playlist to w.
+// The URLs of the segments are written as is so that they can be relative
+// to the location of the playlist.
+func (p MediaPlaylist) Encode(w io.Writer) error {
+	var b encoder
+	b.WriteString("#EXTM3U\n")
+	if p.Version > 0 {
+		fmt.Fprintf(&b, "#EXT-X-VERSION:%d\n", p.Version)
+	}
+	target := (p.TargetDuration + time.Second - 1) / time.Second
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", target)
+	if len(p.Type) > 0 {
+		fmt.Fprintf(&b, "#EXT-X-PLAYLIST-TYPE:%s\n", p.Type)
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.Sequence)
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence)
+	}
+	if p.LiveSequence > 0 {
+		fmt.Fprintf(&b, "#EXT-X-TWITCH-LIVE-SEQUENCE:%d\n", p.LiveSequence)
+	}
+	if p.Elapsed > 0 {
+		fmt.Fprintf(&b, "#EXT-X-TWITCH-ELAPSED-SECS:%s\n", formatSeconds(p.Elapsed))
+	}
+	if p.Total > 0 {
+		fmt.Fprintf(&b, "#EXT-X-TWITCH-TOTAL-SECS:%s\n", formatSeconds(p.Total))
+	}
+	for _, d := range p.DateRanges {
+		d.encode(&b)
+	}
+
+	var key *Key
+	var initMap *Map
+	for i, s := range p.Segments {
+		if s.Discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if !sameKey(key, s.Key) {
+			if s.Key == nil {
+				b.WriteString("#EXT-X-KEY:METHOD=NONE\n")
+			} else {
+				s.Key.encode(&b)
+			}
+			key = s.Key
+		}
+		if s.Map != nil && (initMap == nil || initMap.URI != s.Map.URI || !sameByteRange(initMap.ByteRange, s.Map.ByteRange)) {
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=%s", b.quote(s.Map.URI))
+			if r := s.Map.ByteRange; r != nil {
+				fmt.Fprintf(&b, ",BYTERANGE=\"%d@%d\"", r.Length, r.Offset)
+			}
+			b.WriteString("\n")
+		}
+		initMap = s.Map
+		if !s.ProgramDateTime.IsZero() {
+			// Dates following the previous segment are implied.
+			implied := i > 0 && !s.Discontinuity &&
+				s.ProgramDateTime.Equal(p.Segments[i-1].ProgramDateTime.Add(p.Segments[i-1].Duration))
+			if !implied {
+				fmt.Fprintf(&b, "#EXT-X-PROGRAM-DATE-TIME:%s\n", s.ProgramDateTime.Format(time.RFC3339Nano))
+			}
+		}
+		if s.Gap {
+			b.WriteString("#EXT-X-GAP\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%s,%s\n", formatSeconds(s.Duration), s.Title)
+		if r := s.ByteRange; r != nil {
+			fmt.Fprintf(&b, "#EXT-X-BYTERANGE:%d@%d\n", r.Length, r.Offset)
+		}
+		fmt.Fprintf(&b, "%s\n", s.URL)
+	}
+	for _, u := range p.Prefetch {
+		fmt.Fprintf(&b, "#EXT-X-TWITCH-PREFETCH:%s\n", u)
+	}
+	if p.Ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	if b.err != nil {
+		return b.err
+	}
+	_, err := w.Write(b.Bytes())
+	return errors.WithStack(err)
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func sameByteRange(a, b *ByteRange) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
 
-		// Discard line.
+func sameKey(a, b *Key) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return a.Method == b.Method && a.URI == b.URI && bytes.Equal(a.IV, b.IV) &&
+		a.KeyFormat == b.KeyFormat && a.KeyFormatVersions == b.KeyFormatVersions
+}
 
-	return playlist, errors.WithStack(scanner.Err())
+func (k *Key) encode(b *encoder) {
+	fmt.Fprintf(b, "#EXT-X-KEY:METHOD=%s", k.Method)
+	if len(k.URI) > 0 {
+		fmt.Fprintf(b, ",URI=%s", b.quote(k.URI))
+	}
+	if len(k.IV) > 0 {
+		fmt.Fprintf(b, ",IV=0x%X", k.IV)
+	}
+	if len(k.KeyFormat) > 0 {
+		fmt.Fprintf(b, ",KEYFORMAT=%s", b.quote(k.KeyFormat))
+	}
+	if len(k.KeyFormatVersions) > 0 {
+		fmt.Fprintf(b, ",KEYFORMATVERSIONS=%s", b.quote(k.KeyFormatVersions))
+	}
+	b.WriteString("\n")
+}
+
+func (d DateRange) encode(b *encoder) {
+	fmt.Fprintf(b, "#EXT-X-DATERANGE:ID=%s", b.quote(d.ID))
+	if len(d.Class) > 0 {
+		fmt.Fprintf(b, ",CLASS=%s", b.quote(d.Class))
+	}
+	fmt.Fprintf(b, ",START-DATE=%s", b.quote(d.StartDate.Format(time.RFC3339Nano)))
+	if !d.EndDate.IsZero() {
+		fmt.Fprintf(b, ",END-DATE=%s", b.quote(d.EndDate.Format(time.RFC3339Nano)))
+	}
+	if d.Duration > 0 {
+		fmt.Fprintf(b, ",DURATION=%s", formatSeconds(d.Duration))
+	}
+	if d.PlannedDuration > 0 {
+		fmt.Fprintf(b, ",PLANNED-DURATION=%s", formatSeconds(d.PlannedDuration))
+	}
+	var keys []string
+	for k := range d.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := d.Attributes[k]
+		if v.Quoted {
+			fmt.Fprintf(b, ",%s=%s", k, b.quote(v.Value))
+			continue
+		}
+		fmt.Fprintf(b, ",%s=%s", k, v.Value)
+	}
+	b.WriteString("\n")
 }
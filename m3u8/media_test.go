@@ -34,6 +34,8 @@ http://custom.com/720p30/1.ts?query=val
 	assert.Equal(t, "EVENT", playlist.Type)
 	assert.Equal(t, 2, playlist.Sequence)
 	assert.True(t, playlist.Ended)
+	assert.Equal(t, time.Duration(0), playlist.Elapsed)
+	assert.Equal(t, 578*time.Second+690*time.Millisecond, playlist.Total)
 
 	assert.Equal(t, 2, len(playlist.Segments))
 
@@ -45,3 +47,189 @@ http://custom.com/720p30/1.ts?query=val
 	assert.Equal(t, 3, playlist.Segments[1].Number)
 	assert.Equal(t, "http://custom.com/720p30/1.ts?query=val", playlist.Segments[1].URL)
 }
+
+func TestMedia_Tags(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:5
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXT-X-PROGRAM-DATE-TIME:2020-01-31T12:00:00.000Z
+#EXTINF:10.000,first
+#EXT-X-BYTERANGE:1000@720
+media.mp4
+#EXTINF:10.000,
+#EXT-X-BYTERANGE:2000
+media.mp4
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/key?id=1",IV=0x000102030405060708090A0B0C0D0E0F
+#EXT-X-MAP:URI="init2.mp4"
+#EXTINF:4.000,
+2.mp4
+#EXT-X-KEY:METHOD=NONE
+#EXT-X-GAP
+#EXTINF:4.000,
+3.mp4
+#EXT-X-ENDLIST`)
+	playlist, err := m3u8.Media(bytes.NewReader(b), "http://example.com/chunked/index.m3u8")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, 7, playlist.Version)
+	assert.Equal(t, 2, playlist.DiscontinuitySequence)
+	assert.Equal(t, 4, len(playlist.Segments))
+
+	first, second, third, fourth := playlist.Segments[0], playlist.Segments[1], playlist.Segments[2], playlist.Segments[3]
+
+	assert.Equal(t, "first", first.Title)
+	assert.Equal(t, "http://example.com/chunked/media.mp4", first.URL)
+	assert.Equal(t, &m3u8.ByteRange{Length: 1000, Offset: 720}, first.ByteRange)
+	assert.Equal(t, &m3u8.Map{URI: "http://example.com/chunked/init.mp4", ByteRange: &m3u8.ByteRange{Length: 720}}, first.Map)
+	assert.Equal(t, time.Date(2020, 1, 31, 12, 0, 0, 0, time.UTC), first.ProgramDateTime)
+	assert.Equal(t, 2, first.DiscontinuitySequence)
+	assert.Nil(t, first.Key)
+
+	assert.Equal(t, "", second.Title)
+	assert.Equal(t, &m3u8.ByteRange{Length: 2000, Offset: 1720}, second.ByteRange)
+	assert.Equal(t, time.Date(2020, 1, 31, 12, 0, 10, 0, time.UTC), second.ProgramDateTime)
+	assert.False(t, second.Discontinuity)
+
+	assert.True(t, third.Discontinuity)
+	assert.Equal(t, 3, third.DiscontinuitySequence)
+	assert.Nil(t, third.ByteRange)
+	assert.True(t, third.ProgramDateTime.IsZero())
+	assert.Equal(t, "http://example.com/chunked/init2.mp4", third.Map.URI)
+	assert.Equal(t, &m3u8.Key{
+		Method: "AES-128",
+		URI:    "https://keys.example.com/key?id=1",
+		IV:     []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+	}, third.Key)
+	assert.False(t, third.Gap)
+
+	assert.Nil(t, fourth.Key)
+	assert.True(t, fourth.Gap)
+	assert.Equal(t, 8, fourth.Number)
+}
+
+func TestMedia_Twitch(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-TWITCH-LIVE-SEQUENCE:1500
+#EXT-X-TWITCH-ELAPSED-SECS:600.500
+#EXT-X-TWITCH-TOTAL-SECS:606.500
+#EXT-X-DATERANGE:ID="stitched-ad-1",CLASS="twitch-stitched-ad",START-DATE="2020-01-31T12:00:02.000Z",DURATION=4.000,X-TV-TWITCH-AD-POD-LENGTH="1"
+#EXT-X-PROGRAM-DATE-TIME:2020-01-31T12:00:00.000Z
+#EXTINF:2.000,live
+100.ts
+#EXTINF:2.000,Amazon|123
+101.ts
+#EXTINF:2.000,Amazon|123
+102.ts
+#EXTINF:2.000,live
+103.ts
+#EXT-X-TWITCH-PREFETCH:104.ts
+#EXT-X-TWITCH-PREFETCH:https://custom.com/105.ts`)
+	playlist, err := m3u8.Media(bytes.NewReader(b), "http://example.com/live/index.m3u8")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, 1500, playlist.LiveSequence)
+	assert.Equal(t, 600*time.Second+500*time.Millisecond, playlist.Elapsed)
+	assert.Equal(t, 606*time.Second+500*time.Millisecond, playlist.Total)
+	assert.Equal(t, []string{"http://example.com/live/104.ts", "https://custom.com/105.ts"}, playlist.Prefetch)
+
+	assert.Equal(t, 1, len(playlist.DateRanges))
+	d := playlist.DateRanges[0]
+	assert.True(t, d.Ad())
+	assert.Equal(t, time.Date(2020, 1, 31, 12, 0, 2, 0, time.UTC), d.StartDate)
+	assert.Equal(t, time.Date(2020, 1, 31, 12, 0, 6, 0, time.UTC), d.End())
+	assert.Equal(t, map[string]m3u8.AttributeValue{"X-TV-TWITCH-AD-POD-LENGTH": {Value: "1", Quoted: true}}, d.Attributes)
+
+	var ads []bool
+	for _, segment := range playlist.Segments {
+		ads = append(ads, segment.Ad)
+	}
+	assert.Equal(t, []bool{false, true, true, false}, ads)
+}
+
+func TestMediaEncode(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:10
+#EXT-X-PLAYLIST-TYPE:EVENT
+#EXT-X-MEDIA-SEQUENCE:5
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXT-X-TWITCH-ELAPSED-SECS:10.5
+#EXT-X-TWITCH-TOTAL-SECS:48.5
+#EXT-X-DATERANGE:ID="stitched-ad-1",CLASS="twitch-stitched-ad",START-DATE="2020-01-31T12:00:10Z",DURATION=10,X-COM-EXAMPLE-ID=0x1F,X-COM-EXAMPLE-NAME="café",X-COM-EXAMPLE-SCORE=2.5,X-TV-TWITCH-AD-POD-LENGTH="1"
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXT-X-PROGRAM-DATE-TIME:2020-01-31T12:00:00Z
+#EXTINF:10,live
+#EXT-X-BYTERANGE:1000@720
+media.mp4
+#EXTINF:10.01,Amazon|1
+#EXT-X-BYTERANGE:2000@1720
+media.mp4
+#EXT-X-DISCONTINUITY
+#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/key?id=1",IV=0x000102030405060708090A0B0C0D0E0F
+#EXT-X-MAP:URI="init2.mp4"
+#EXTINF:4,
+2.mp4
+#EXT-X-KEY:METHOD=NONE
+#EXT-X-GAP
+#EXTINF:4,
+3.mp4
+#EXT-X-TWITCH-PREFETCH:4.mp4
+#EXT-X-ENDLIST
+`)
+	playlist, err := m3u8.Media(bytes.NewReader(b), "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var encoded bytes.Buffer
+	if err := playlist.Encode(&encoded); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, string(b), encoded.String())
+
+	decoded, err := m3u8.Media(&encoded, "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	assert.Equal(t, playlist, decoded)
+}
+
+func TestMediaEncode_InvalidQuotedString(t *testing.T) {
+	playlist := m3u8.MediaPlaylist{Segments: []m3u8.MediaSegment{{
+		Duration: time.Second,
+		URL:      "0.ts",
+		Map:      &m3u8.Map{URI: `init".mp4`},
+	}}}
+	assert.Error(t, playlist.Encode(&bytes.Buffer{}))
+}
+
+func TestMedia_Muted(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.000,
+0.ts
+#EXTINF:10.000,
+1-muted.ts
+#EXTINF:10.000,
+2-unmuted.ts
+#EXTINF:10.000,
+3-muted.ts?token=1
+#EXT-X-ENDLIST`)
+	playlist, err := m3u8.Media(bytes.NewReader(b), "http://example.com/chunked/index-dvr.m3u8")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var muted []bool
+	for _, segment := range playlist.Segments {
+		muted = append(muted, segment.Muted)
+	}
+	assert.Equal(t, []bool{false, true, false, true}, muted)
+}
@@ -0,0 +1,124 @@
+package m3u8
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseError describes a line of a playlist that cannot be parsed.
+type ParseError struct {
+	// Line is the number of the line, starting at 1.
+	Line int
+	// Tag is the tag of the line such as "#EXTINF". It is empty for URI lines.
+	Tag string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Tag) == 0 {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: %s: %v", e.Line, e.Tag, e.Err)
+}
+
+// Cause returns the underlying error.
+func (e *ParseError) Cause() error { return e.Err }
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Decoder parses playlists.
+// The zero value stops at the first malformed line.
+type Decoder struct {
+	// Lenient skips the malformed lines instead of returning an error.
+	// Each skipped line is recorded in Warnings.
+	Lenient  bool
+	Warnings []*ParseError
+}
+
+// fail reports err for the line n.
+// It returns nil if the line must be skipped.
+func (d *Decoder) fail(n int, line string, err error) error {
+	e := &ParseError{Line: n, Err: err}
+	if strings.HasPrefix(line, "#") {
+		e.Tag = line
+		if i := strings.Index(line, ":"); i >= 0 {
+			e.Tag = line[:i]
+		}
+	}
+	if !d.Lenient {
+		return e
+	}
+	d.Warnings = append(d.Warnings, e)
+	return nil
+}
+
+// attributes parses an attribute list such as `NAME="720p, 60fps",BANDWIDTH=3000000`.
+// Quoted values can contain commas and equal signs.
+//
+// https://tools.ietf.org/html/rfc8216#section-4.2
+func attributes(line string) (map[string]string, error) {
+	attr, _, err := quotedAttributes(line)
+	return attr, err
+}
+
+// quotedAttributes parses an attribute list like attributes and also reports
+// which values are quoted strings.
+func quotedAttributes(line string) (attr map[string]string, quoted map[string]bool, err error) {
+	attr, quoted = map[string]string{}, map[string]bool{}
+	for len(line) > 0 {
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return attr, quoted, errors.Errorf("malformed attribute %q", line)
+		}
+		name := strings.TrimSpace(line[:eq])
+		if len(name) == 0 || strings.ContainsAny(name, `,"`) {
+			return attr, quoted, errors.Errorf("malformed attribute %q", line[:eq])
+		}
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			end := strings.Index(line[1:], `"`)
+			if end < 0 {
+				return attr, quoted, errors.Errorf("unterminated quoted string in attribute %s", name)
+			}
+			value, line = line[1:end+1], line[end+2:]
+			quoted[name] = true
+		} else {
+			end := strings.Index(line, ",")
+			if end < 0 {
+				end = len(line)
+			}
+			value, line = line[:end], line[end:]
+		}
+		if len(line) > 0 {
+			if line[0] != ',' {
+				return attr, quoted, errors.Errorf("unexpected characters after attribute %s", name)
+			}
+			line = line[1:]
+		}
+		attr[name] = value
+	}
+	return attr, quoted, nil
+}
+
+// encoder is a buffer keeping the first error met while encoding a playlist.
+type encoder struct {
+	bytes.Buffer
+	err error
+}
+
+// quote returns s as a quoted-string. A quoted-string cannot contain
+// double quotes or line breaks, which are reported as an error.
+//
+// https://tools.ietf.org/html/rfc8216#section-4.2
+func (e *encoder) quote(s string) string {
+	if strings.ContainsAny(s, "\"\r\n") && e.err == nil {
+		e.err = errors.Errorf("%q cannot be written as a quoted-string", s)
+	}
+	return `"` + s + `"`
+}
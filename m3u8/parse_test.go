@@ -0,0 +1,115 @@
+package m3u8_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+)
+
+const malformedMedia = `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXTINF:10.000,
+0.ts
+#EXTINF:ten,
+1.ts
+#EXT-X-BYTERANGE:abc
+#EXTINF:10.000,
+2.ts
+#EXTINF:5.000,
+`
+
+func TestMedia_ParseError(t *testing.T) {
+	_, err := m3u8.Media(bytes.NewReader([]byte(malformedMedia)), "")
+	require.Error(t, err)
+	perr, ok := err.(*m3u8.ParseError)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, 5, perr.Line)
+	assert.Equal(t, "#EXTINF", perr.Tag)
+	assert.Contains(t, err.Error(), "line 5: #EXTINF: ")
+
+	_, err = m3u8.Media(bytes.NewReader([]byte("#EXTM3V\n")), "")
+	require.Error(t, err)
+	assert.Equal(t, 1, err.(*m3u8.ParseError).Line)
+}
+
+func TestMedia_Lenient(t *testing.T) {
+	d := m3u8.Decoder{Lenient: true}
+	playlist, err := d.Media(bytes.NewReader([]byte(malformedMedia)), "")
+	require.NoError(t, err)
+
+	// 1.ts is discarded since its EXTINF tag is malformed.
+	require.Len(t, playlist.Segments, 2)
+	assert.Equal(t, "0.ts", playlist.Segments[0].URL)
+	assert.Equal(t, "2.ts", playlist.Segments[1].URL)
+	assert.Equal(t, 1, playlist.Segments[1].Number)
+
+	require.Len(t, d.Warnings, 3)
+	assert.Equal(t, 5, d.Warnings[0].Line)
+	assert.Equal(t, "#EXTINF", d.Warnings[0].Tag)
+	assert.Equal(t, 7, d.Warnings[1].Line)
+	assert.Equal(t, "#EXT-X-BYTERANGE", d.Warnings[1].Tag)
+	assert.Equal(t, 10, d.Warnings[2].Line)
+	assert.Equal(t, io.ErrUnexpectedEOF, errors.Cause(d.Warnings[2]))
+}
+
+func TestMaster_Attributes(t *testing.T) {
+	b := []byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="audio=aac,stereo",NAME="English, stereo",DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=128000,CODECS="mp4a.40.2",AUDIO="audio=aac,stereo"
+audio.m3u8`)
+	playlist, err := m3u8.Master(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	require.Len(t, playlist.Variants, 1)
+	v := playlist.Variants[0]
+	assert.Equal(t, "audio=aac,stereo", v.Audio)
+	assert.Equal(t, []string{"mp4a.40.2"}, v.Codecs)
+	assert.Equal(t, []m3u8.Alternative{{
+		Type:    "AUDIO",
+		GroupID: "audio=aac,stereo",
+		Name:    "English, stereo",
+		Default: true,
+	}}, v.Alternatives)
+}
+
+const malformedMaster = `#EXTM3U
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English"
+#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"
+#EXT-X-MEDIA:TYPE=HOLOGRAM,GROUP-ID="holo",NAME="3D"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,NAME="unterminated
+low.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=2560000
+mid.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=7680000
+`
+
+func TestMaster_ParseError(t *testing.T) {
+	_, err := m3u8.Master(bytes.NewReader([]byte(malformedMaster)))
+	require.Error(t, err)
+	perr, ok := err.(*m3u8.ParseError)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, 5, perr.Line)
+	assert.Equal(t, "#EXT-X-STREAM-INF", perr.Tag)
+}
+
+func TestMaster_Lenient(t *testing.T) {
+	d := m3u8.Decoder{Lenient: true}
+	playlist, err := d.Master(bytes.NewReader([]byte(malformedMaster)))
+	require.NoError(t, err)
+
+	require.Len(t, playlist.Variants, 1)
+	assert.Equal(t, "mid.m3u8", playlist.Variants[0].URL)
+
+	require.Len(t, d.Warnings, 3)
+	assert.Equal(t, 5, d.Warnings[0].Line)
+	assert.Equal(t, 9, d.Warnings[1].Line)
+	assert.Equal(t, 4, d.Warnings[2].Line)
+	assert.Equal(t, "#EXT-X-MEDIA", d.Warnings[2].Tag)
+}
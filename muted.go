@@ -0,0 +1,86 @@
+package twitchdl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+)
+
+// unmutedURLs returns the URLs where the original audio of the muted segment
+// at URL might still be available.
+func unmutedURLs(URL string) []string {
+	i := strings.LastIndex(URL, "-muted.ts")
+	if i < 0 {
+		return nil
+	}
+	prefix, suffix := URL[:i], URL[i+len("-muted.ts"):]
+	return []string{prefix + ".ts" + suffix, prefix + "-unmuted.ts" + suffix}
+}
+
+// unmutedDownload returns the downloadFunc of the muted segment that tries
+// the unmuted URLs of the segment first and falls back to the muted one.
+func unmutedDownload(ctx context.Context, client *http.Client, segment m3u8.MediaSegment, withMap bool, policy RetryPolicy) (downloadFunc, error) {
+	var fns []downloadFunc
+	for _, URL := range append(unmutedURLs(segment.URL), segment.URL) {
+		s := segment
+		s.URL = URL
+		fn, err := segmentDownload(ctx, client, s, withMap, policy)
+		if err != nil {
+			return nil, err
+		}
+		fns = append(fns, fn)
+	}
+	return func() (io.ReadCloser, error) {
+		var err error
+		for _, fn := range fns {
+			var body io.ReadCloser
+			if body, err = fn(); err == nil || ctx.Err() != nil {
+				return body, err
+			}
+		}
+		return nil, err
+	}, nil
+}
+
+// mutedRanges returns the ranges of the VOD covered by the muted segments
+// among segments. The position of the segments inside the VOD starts at media.Elapsed.
+func mutedRanges(media m3u8.MediaPlaylist, segments []m3u8.MediaSegment) []Range {
+	muted := map[int]bool{}
+	for _, segment := range segments {
+		if segment.Muted {
+			muted[segment.Number] = true
+		}
+	}
+	var ranges []Range
+	position := media.Elapsed
+	for _, segment := range media.Segments {
+		if muted[segment.Number] {
+			ranges = appendRange(ranges, Range{Start: position, End: position + segment.Duration})
+		}
+		position += segment.Duration
+	}
+	return ranges
+}
+
+// appendRange appends r to the sorted ranges, merging it with the last range
+// if they overlap or touch.
+func appendRange(ranges []Range, r Range) []Range {
+	if n := len(ranges); n > 0 && r.Start <= ranges[n-1].End {
+		if r.End > ranges[n-1].End {
+			ranges[n-1].End = r.End
+		}
+		return ranges
+	}
+	return append(ranges, r)
+}
+
+// Muted returns the ranges of the VOD between the start and end timestamps
+// whose audio was muted by twitch, as listed by the playlist. They are
+// reported even if the muted segments were unmuted with WithUnmuted or
+// skipped with WithSkipMuted. It is empty for clips.
+func (r *Merger) Muted() []Range {
+	return r.muted
+}
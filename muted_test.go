@@ -0,0 +1,72 @@
+package twitchdl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownload_Muted(t *testing.T) {
+	// Segments 2, 3 and 6 are muted. The original audio of segment 3 is still available.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/gql":
+			fmt.Fprint(w, `{"data":{"videoPlaybackAccessToken":{"value":"token","signature":"sig"}}}`)
+		case strings.HasPrefix(r.URL.Path, "/vod/"):
+			fmt.Fprint(w, "#EXTM3U\n"+
+				`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p",AUTOSELECT=YES,DEFAULT=YES`+"\n"+
+				`#EXT-X-STREAM-INF:BANDWIDTH=6847192,VIDEO="chunked"`+"\n"+
+				"https://vod.example.com/chunked/index-dvr.m3u8\n")
+		case strings.HasSuffix(r.URL.Path, ".m3u8"):
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n")
+			for i := 0; i < 8; i++ {
+				if i == 2 || i == 3 || i == 6 {
+					fmt.Fprintf(w, "#EXTINF:10.000,\n%d-muted.ts\n", i)
+					continue
+				}
+				fmt.Fprintf(w, "#EXTINF:10.000,\n%d.ts\n", i)
+			}
+			fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+		case r.URL.Path == "/chunked/2.ts" || r.URL.Path == "/chunked/2-unmuted.ts" ||
+			r.URL.Path == "/chunked/6.ts" || r.URL.Path == "/chunked/6-unmuted.ts":
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			fmt.Fprintf(w, "[%s]", strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/chunked/"), ".ts"))
+		}
+	}))
+	defer srv.Close()
+
+	download := func(opts ...Option) (*Merger, string) {
+		m, err := Download(context.Background(), testClient(t, srv), "id", "1", Quality{Name: "1080p"}, 10*time.Second, 0, opts...)
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(m)
+		require.NoError(t, err)
+		return m, string(b)
+	}
+
+	m, b := download()
+	assert.Equal(t, "[1][2-muted][3-muted][4][5][6-muted][7]", b)
+	assert.Equal(t, []Range{{20 * time.Second, 40 * time.Second}, {60 * time.Second, 70 * time.Second}}, m.Muted())
+
+	m, b = download(WithUnmuted())
+	assert.Equal(t, "[1][2-muted][3][4][5][6-muted][7]", b)
+	assert.Equal(t, []Range{{20 * time.Second, 40 * time.Second}, {60 * time.Second, 70 * time.Second}}, m.Muted())
+
+	m, b = download(WithSkipMuted(), WithUnmuted())
+	assert.Equal(t, "[1][4][5][7]", b)
+	assert.Equal(t, []Range{{20 * time.Second, 40 * time.Second}, {60 * time.Second, 70 * time.Second}}, m.Muted())
+}
+
+func TestUnmutedURLs(t *testing.T) {
+	assert.Equal(t, []string{"https://example.com/12.ts?t=1", "https://example.com/12-unmuted.ts?t=1"},
+		unmutedURLs("https://example.com/12-muted.ts?t=1"))
+	assert.Empty(t, unmutedURLs("https://example.com/12.ts"))
+}
@@ -0,0 +1,104 @@
+package twitchdl
+
+import (
+	"net/http"
+
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+// Option configures a download.
+type Option func(*options)
+
+type options struct {
+	concurrency int
+	retry       RetryPolicy
+	journal     *Journal
+	middlewares []twitch.Middleware
+	skipAds     bool
+	unmute      bool
+	skipMuted   bool
+	oauthToken  string
+}
+
+func newOptions(opts []Option) options {
+	o := options{concurrency: 1, retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// newAPI returns the twitch API client configured by o.
+func newAPI(client *http.Client, clientID string, o options) twitch.Client {
+	return twitch.New(client, clientID, o.middlewares...).WithOAuthToken(o.oauthToken)
+}
+
+// WithConcurrency sets the number of chunks downloaded in parallel.
+// Chunks are buffered in memory and the returned Merger still reads them in order.
+// At most n chunks are buffered ahead of the reader.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		if n < 1 {
+			n = 1
+		}
+		o.concurrency = n
+	}
+}
+
+// WithRetry sets the policy used to retry failed chunk downloads.
+// DefaultRetryPolicy is used when this option is omitted.
+func WithRetry(p RetryPolicy) Option {
+	return func(o *options) {
+		if p.MaxAttempts < 1 {
+			p.MaxAttempts = 1
+		}
+		o.retry = p
+	}
+}
+
+// WithJournal records every chunk written into j and skips the chunks
+// already recorded in j. Call j.Truncate on the partial output beforehand.
+func WithJournal(j *Journal) Option {
+	return func(o *options) {
+		o.journal = j
+	}
+}
+
+// WithMiddleware adds middlewares to the requests made to the twitch API.
+func WithMiddleware(middlewares ...twitch.Middleware) Option {
+	return func(o *options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// WithUnmuted tries to download the original audio of the segments muted by
+// twitch and falls back to the muted segments if it is not available.
+func WithUnmuted() Option {
+	return func(o *options) {
+		o.unmute = true
+	}
+}
+
+// WithSkipMuted drops the segments muted by twitch.
+// It takes precedence over WithUnmuted.
+func WithSkipMuted() Option {
+	return func(o *options) {
+		o.skipMuted = true
+	}
+}
+
+// WithOAuthToken authenticates the requests to the twitch API with the OAuth
+// token of a user to access subscriber-only and private VODs.
+func WithOAuthToken(token string) Option {
+	return func(o *options) {
+		o.oauthToken = token
+	}
+}
+
+// WithSkipAds drops the ads stitched by twitch into a live stream.
+// It only applies to Record.
+func WithSkipAds() Option {
+	return func(o *options) {
+		o.skipAds = true
+	}
+}
@@ -0,0 +1,117 @@
+package twitchdl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+// Quality describes a rendition of a VOD or a clip.
+// Only Name is required to select a quality with Download or Download_clip.
+type Quality struct {
+	// Name is the name of the quality such as "1080p60" or "Audio Only".
+	// Subtitles and closed captions are prefixed by SubtitlesPrefix and CaptionsPrefix.
+	Name string
+	// GroupID is the group of the rendition inside the master playlist
+	// such as "chunked". It is empty for clips.
+	GroupID   string
+	Width     int
+	Height    int
+	FPS       float64
+	Bandwidth int
+	Codecs    []string
+	// AudioOnly is true if the quality has no video.
+	AudioOnly bool
+	// Source is true if the quality is the original stream, not a transcode.
+	Source bool
+}
+
+func (q Quality) String() string {
+	return q.Name
+}
+
+// vodQualities returns the qualities of master: the video and audio
+// alternatives followed by the subtitles and closed captions.
+func vodQualities(master m3u8.MasterPlaylist) []Quality {
+	var qualities []Quality
+	for _, variant := range master.Variants {
+		for _, alt := range variant.Alternatives {
+			if alt.Type != "VIDEO" && alt.Type != "AUDIO" {
+				continue
+			}
+			q := Quality{
+				Name:      alt.Name,
+				GroupID:   alt.GroupID,
+				Width:     variant.Resolution.Width,
+				Height:    variant.Resolution.Height,
+				FPS:       variant.FrameRate,
+				Bandwidth: variant.Bandwidth,
+			}
+			for _, codec := range variant.Codecs {
+				if codec = strings.TrimSpace(codec); len(codec) > 0 {
+					q.Codecs = append(q.Codecs, codec)
+				}
+			}
+			if q.FPS == 0 {
+				// Twitch names the qualities "<height>p<fps>", e.g. "720p60".
+				var height, fps int
+				if n, _ := fmt.Sscanf(alt.Name, "%dp%d", &height, &fps); n == 2 {
+					q.FPS = float64(fps)
+				}
+			}
+			q.AudioOnly = alt.GroupID == "audio_only" || (q.Height == 0 && len(q.Codecs) > 0 && audioCodecs(q.Codecs))
+			q.Source = alt.GroupID == "chunked" || strings.Contains(strings.ToLower(alt.Name), "source")
+			qualities = append(qualities, q)
+		}
+	}
+	seen := map[string]bool{}
+	for _, variant := range master.Variants {
+		for _, alt := range variant.Alternatives {
+			var name string
+			switch alt.Type {
+			case "SUBTITLES":
+				name = SubtitlesPrefix + alt.Name
+			case "CLOSED-CAPTIONS":
+				name = CaptionsPrefix + alt.Name
+			default:
+				continue
+			}
+			if !seen[name] {
+				seen[name] = true
+				qualities = append(qualities, Quality{Name: name, GroupID: alt.GroupID})
+			}
+		}
+	}
+	return qualities
+}
+
+// audioCodecs reports whether all codecs are audio codecs.
+func audioCodecs(codecs []string) bool {
+	for _, codec := range codecs {
+		if !strings.HasPrefix(codec, "mp4a") && !strings.HasPrefix(codec, "ac-3") && !strings.HasPrefix(codec, "ec-3") {
+			return false
+		}
+	}
+	return true
+}
+
+// clipQualities returns the qualities of the clip renditions.
+// The rendition with the highest resolution is the source.
+func clipQualities(clips []twitch.Clip) []Quality {
+	var qualities []Quality
+	source := -1
+	for i, clip := range clips {
+		q := Quality{Name: clip.Quality_option, FPS: float64(clip.FrameRate)}
+		fmt.Sscanf(clip.Quality, "%d", &q.Height)
+		if source < 0 || q.Height > qualities[source].Height {
+			source = i
+		}
+		qualities = append(qualities, q)
+	}
+	if source >= 0 {
+		qualities[source].Source = true
+	}
+	return qualities
+}
@@ -0,0 +1,57 @@
+package twitchdl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/jybp/twitch-downloader/twitch"
+)
+
+func TestVODQualities(t *testing.T) {
+	master, err := m3u8.Master(bytes.NewReader([]byte(`#EXTM3U
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p60 (source)",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=6847192,CODECS="avc1.64002A,mp4a.40.2",RESOLUTION=1920x1080,VIDEO="chunked",FRAME-RATE=59.940
+https://example.com/chunked/index-dvr.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="720p30",NAME="720p",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=2303475,CODECS="avc1.4D401F,mp4a.40.2",RESOLUTION=1280x720,VIDEO="720p30"
+https://example.com/720p30/index-dvr.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="480p30",NAME="480p30",AUTOSELECT=YES,DEFAULT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=1427999,CODECS="avc1.4D401F,mp4a.40.2",RESOLUTION=852x480,VIDEO="480p30"
+https://example.com/480p30/index-dvr.m3u8
+#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="audio_only",NAME="Audio Only",AUTOSELECT=NO,DEFAULT=NO
+#EXT-X-STREAM-INF:BANDWIDTH=160000,CODECS="mp4a.40.2",VIDEO="audio_only"
+https://example.com/audio_only/index-dvr.m3u8`)))
+	require.NoError(t, err)
+	assert.Equal(t, []Quality{
+		{Name: "1080p60 (source)", GroupID: "chunked", Width: 1920, Height: 1080, FPS: 59.94, Bandwidth: 6847192,
+			Codecs: []string{"avc1.64002A", "mp4a.40.2"}, Source: true},
+		{Name: "720p", GroupID: "720p30", Width: 1280, Height: 720, Bandwidth: 2303475,
+			Codecs: []string{"avc1.4D401F", "mp4a.40.2"}},
+		{Name: "480p30", GroupID: "480p30", Width: 852, Height: 480, FPS: 30, Bandwidth: 1427999,
+			Codecs: []string{"avc1.4D401F", "mp4a.40.2"}},
+		{Name: "Audio Only", GroupID: "audio_only", Bandwidth: 160000, Codecs: []string{"mp4a.40.2"}, AudioOnly: true},
+	}, vodQualities(master))
+
+	variant, err := findVariant(master, Quality{Name: "720p", GroupID: "720p30"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/720p30/index-dvr.m3u8", variant.URL)
+	_, err = findVariant(master, Quality{Name: "720p", GroupID: "chunked"})
+	assert.Error(t, err)
+}
+
+func TestClipQualities(t *testing.T) {
+	qualities := clipQualities([]twitch.Clip{
+		{Quality: "720", FrameRate: 60, Quality_option: "720p60"},
+		{Quality: "1080", FrameRate: 60, Quality_option: "1080p60"},
+		{Quality: "360", FrameRate: 30, Quality_option: "360p30"},
+	})
+	assert.Equal(t, []Quality{
+		{Name: "720p60", Height: 720, FPS: 60},
+		{Name: "1080p60", Height: 1080, FPS: 60, Source: true},
+		{Name: "360p30", Height: 360, FPS: 30},
+	}, qualities)
+}
@@ -0,0 +1,114 @@
+package twitchdl
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Range is a section of a VOD. End is zero to go until the end of the VOD.
+type Range struct {
+	Start, End time.Duration
+}
+
+// ParseRanges parses a comma separated list of ranges such as "10m-15m,1h2m-1h10m".
+// The start or the end of a range can be omitted, as in "-5m" or "1h-".
+// The ranges must be in chronological order and must not overlap.
+func ParseRanges(s string) ([]Range, error) {
+	var ranges []Range
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.Split(strings.TrimSpace(part), "-")
+		if len(bounds) != 2 {
+			return nil, errors.Errorf("invalid range %q", part)
+		}
+		var r Range
+		var err error
+		if len(bounds[0]) > 0 {
+			if r.Start, err = time.ParseDuration(bounds[0]); err != nil {
+				return nil, errors.Errorf("invalid range %q: %v", part, err)
+			}
+		}
+		if len(bounds[1]) > 0 {
+			if r.End, err = time.ParseDuration(bounds[1]); err != nil {
+				return nil, errors.Errorf("invalid range %q: %v", part, err)
+			}
+			if r.End <= r.Start {
+				return nil, errors.Errorf("invalid range %q: end is not after start", part)
+			}
+		}
+		if n := len(ranges); n > 0 {
+			if prev := ranges[n-1]; prev.End == 0 || r.Start < prev.End {
+				return nil, errors.Errorf("range %q overlaps the previous range", part)
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// DownloadRanges sets up the download of each range of the VOD "vodID" with
+// quality "quality". The access token and the playlists are retrieved once
+// for all the ranges.
+// The Mergers share the same options so a journal should only be used
+// when they are concatenated with Concat.
+func DownloadRanges(ctx context.Context, client *http.Client, clientID, vodID string, quality Quality, ranges []Range, opts ...Option) ([]*Merger, error) {
+	o := newOptions(opts)
+	media, err := vodMedia(ctx, client, clientID, vodID, quality, o)
+	if err != nil {
+		return nil, err
+	}
+	var mergers []*Merger
+	for _, r := range ranges {
+		m, err := newDownload(ctx, client, media, r.Start, r.End, o)
+		if err != nil {
+			return nil, errors.Wrapf(err, "range %v-%v", r.Start, r.End)
+		}
+		mergers = append(mergers, m)
+	}
+	return mergers, nil
+}
+
+// Concat returns a Merger reading the chunks of mergers one after another.
+// The chunks already read by a previous Merger are skipped so that a segment
+// shared by two consecutive ranges is only downloaded once.
+// mergers must not have been read and must share the same options.
+// The timestamps of the resulting stream jump between ranges, as reported by
+// Discontinuous, which a Concatenated remux.Writer smooths out.
+func Concat(mergers ...*Merger) *Merger {
+	if len(mergers) == 0 {
+		return newMerger(context.Background(), nil, nil, newOptions(nil))
+	}
+	first := mergers[0]
+	m := &Merger{
+		ctx:         first.ctx,
+		concurrency: first.concurrency,
+		journal:     first.journal,
+		offset:      first.offset,
+		position:    first.position,
+		media:       first.media,
+		// The timestamps jump between the ranges.
+		discontinuous: len(mergers) > 1,
+	}
+	last := -1
+	for _, r := range mergers {
+		m.discontinuous = m.discontinuous || r.discontinuous
+		for _, muted := range r.muted {
+			m.muted = appendRange(m.muted, muted)
+		}
+		for i, fn := range r.downloads {
+			if r.numbers[i] <= last {
+				continue
+			}
+			last = r.numbers[i]
+			m.downloads = append(m.downloads, fn)
+			m.numbers = append(m.numbers, last)
+			if i < len(r.segments) {
+				m.segments = append(m.segments, r.segments[i])
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,107 @@
+package twitchdl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRanges(t *testing.T) {
+	tcs := []struct {
+		s           string
+		expected    []Range
+		expectedErr bool
+	}{
+		{s: "10m-15m,1h2m-1h10m", expected: []Range{{10 * time.Minute, 15 * time.Minute}, {62 * time.Minute, 70 * time.Minute}}},
+		{s: "-5m, 1h-", expected: []Range{{0, 5 * time.Minute}, {time.Hour, 0}}},
+		{s: "10m", expectedErr: true},
+		{s: "10m-5m", expectedErr: true},
+		{s: "10m-x", expectedErr: true},
+		{s: "10m-15m,12m-20m", expectedErr: true},
+		{s: "10m-,20m-30m", expectedErr: true},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.s, func(t *testing.T) {
+			ranges, err := ParseRanges(tc.s)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ranges)
+		})
+	}
+}
+
+// fakeVOD serves the access token, the playlists and the 10 segments of a VOD.
+type fakeVOD struct {
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func (v *fakeVOD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	v.requests[r.URL.Path]++
+	v.mu.Unlock()
+	switch {
+	case r.URL.Path == "/gql":
+		fmt.Fprint(w, `{"data":{"videoPlaybackAccessToken":{"value":"token","signature":"sig"}}}`)
+	case strings.HasPrefix(r.URL.Path, "/vod/"):
+		fmt.Fprint(w, "#EXTM3U\n"+
+			`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p",AUTOSELECT=YES,DEFAULT=YES`+"\n"+
+			`#EXT-X-STREAM-INF:PROGRAM-ID=1,BANDWIDTH=6847192,CODECS="avc1.42C028,mp4a.40.2",RESOLUTION="1920x1080",VIDEO="chunked"`+"\n"+
+			"https://vod.example.com/chunked/index-dvr.m3u8\n")
+	case strings.HasSuffix(r.URL.Path, ".m3u8"):
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXT-X-MEDIA-SEQUENCE:0\n")
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, "#EXTINF:10.000,\n%d.ts\n", i)
+		}
+		fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+	default:
+		fmt.Fprintf(w, "[%s]", strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/chunked/"), ".ts"))
+	}
+}
+
+func TestDownloadRanges(t *testing.T) {
+	vod := &fakeVOD{requests: map[string]int{}}
+	srv := httptest.NewServer(vod)
+	defer srv.Close()
+
+	ranges := []Range{{5 * time.Second, 25 * time.Second}, {25 * time.Second, 35 * time.Second}, {80 * time.Second, 0}}
+	download := func() []*Merger {
+		mergers, err := DownloadRanges(context.Background(), testClient(t, srv), "id", "1", Quality{Name: "1080p"}, ranges)
+		require.NoError(t, err)
+		require.Len(t, mergers, 3)
+		return mergers
+	}
+
+	mergers := download()
+	var outputs []string
+	for _, m := range mergers {
+		b, err := ioutil.ReadAll(m)
+		require.NoError(t, err)
+		outputs = append(outputs, string(b))
+	}
+	assert.Equal(t, []string{"[0][1][2]", "[2][3]", "[8][9]"}, outputs)
+	assert.Equal(t, []time.Duration{0, 20 * time.Second, 80 * time.Second},
+		[]time.Duration{mergers[0].Position(), mergers[1].Position(), mergers[2].Position()})
+	assert.Equal(t, 1, vod.requests["/gql"])
+	assert.Equal(t, 1, vod.requests["/chunked/index-dvr.m3u8"])
+
+	concat := Concat(download()...)
+	b, err := ioutil.ReadAll(concat)
+	require.NoError(t, err)
+	assert.Equal(t, "[0][1][2][3][8][9]", string(b))
+	assert.Equal(t, 6, concat.Chunks())
+	// Twice by the first mergers and once by the concatenation.
+	assert.Equal(t, 3, vod.requests["/chunked/2.ts"])
+}
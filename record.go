@@ -0,0 +1,217 @@
+package twitchdl
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/pkg/errors"
+)
+
+// Qualities_live returns the qualities available for the live stream of "channel".
+func Qualities_live(ctx context.Context, client *http.Client, clientID, channel string, opts ...Option) ([]Quality, error) {
+	api := newAPI(client, clientID, newOptions(opts))
+	m3u8raw, err := api.LiveM3U8(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	master, err := m3u8.Master(bytes.NewReader(m3u8raw))
+	if err != nil {
+		return nil, err
+	}
+	return vodQualities(master), nil
+}
+
+// Record sets up the recording of the live stream of "channel" with quality "quality",
+// one of the qualities returned by Qualities_live, using the provided http.Client.
+// The recording is actually performed when the returned io.Reader is being read.
+// The returned io.Reader returns io.EOF once the stream has ended or the channel went offline.
+func Record(ctx context.Context, client *http.Client, clientID, channel string, quality Quality, opts ...Option) (*Recorder, error) {
+	o := newOptions(opts)
+	api := newAPI(client, clientID, o)
+	m3u8raw, err := api.LiveM3U8(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	master, err := m3u8.Master(bytes.NewReader(m3u8raw))
+	if err != nil {
+		return nil, err
+	}
+	variant, err := findVariant(master, quality)
+	if err != nil {
+		return nil, err
+	}
+	return newRecorder(ctx, client, variant.URL, o), nil
+}
+
+// Recorder polls a live media playlist and merges its segments into a single io.Reader.
+// Segments are deduplicated using their media sequence number.
+type Recorder struct {
+	ctx         context.Context
+	client      *http.Client
+	playlistURL string
+	retry       RetryPolicy
+	skipAds     bool
+
+	last     int
+	queue    []m3u8.MediaSegment
+	initMap  *m3u8.Map
+	current  io.ReadCloser
+	polled   time.Time
+	interval time.Duration
+	updated  time.Time
+	ended    bool
+	recorded int
+	err      error
+}
+
+// staleTargetDurations is the number of target durations without new segments
+// after which the stream is considered offline.
+const staleTargetDurations = 3
+
+// minPollInterval is the minimum delay between two refreshes of the media playlist.
+var minPollInterval = time.Second
+
+func newRecorder(ctx context.Context, client *http.Client, URL string, o options) *Recorder {
+	return &Recorder{ctx: ctx, client: client, playlistURL: URL, retry: o.retry, skipAds: o.skipAds, last: -1}
+}
+
+// Read allows Recorder to implement io.Reader.
+// Read returns ctx.Err() once the context used to create the Recorder is done.
+func (r *Recorder) Read(p []byte) (int, error) {
+	for {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if err := r.ctx.Err(); err != nil {
+			r.fail(err)
+			return 0, err
+		}
+		if r.current != nil {
+			n, err := r.current.Read(p)
+			if err == io.EOF {
+				err = r.current.Close()
+				r.current = nil
+				r.recorded++
+			}
+			return n, errors.WithStack(err)
+		}
+		if len(r.queue) > 0 {
+			segment := r.queue[0]
+			r.queue = r.queue[1:]
+			withMap := !sameMap(r.initMap, segment.Map)
+			r.initMap = segment.Map
+			if segment.Gap {
+				continue
+			}
+			fn, err := segmentDownload(r.ctx, r.client, segment, withMap, r.retry)
+			if err != nil {
+				r.fail(err)
+				continue
+			}
+			r.current, err = fn()
+			if err != nil {
+				r.fail(r.ctxErr(err))
+			}
+			continue
+		}
+		if r.ended {
+			r.fail(io.EOF)
+			continue
+		}
+		if err := r.poll(); err != nil {
+			r.fail(r.ctxErr(err))
+		}
+	}
+}
+
+func (r *Recorder) fail(err error) {
+	r.err = err
+	if r.current != nil {
+		r.current.Close()
+		r.current = nil
+	}
+}
+
+func (r *Recorder) ctxErr(err error) error {
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// poll waits for the next refresh of the media playlist and queues its new segments.
+func (r *Recorder) poll() error {
+	if wait := r.interval - time.Since(r.polled); !r.polled.IsZero() && wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-r.ctx.Done():
+			timer.Stop()
+			return r.ctx.Err()
+		}
+	}
+	r.polled = time.Now()
+	if r.updated.IsZero() {
+		r.updated = r.polled
+	}
+
+	media, err := fetchMedia(r.ctx, r.client, r.playlistURL)
+	if statusErr, ok := errors.Cause(err).(*StatusError); ok && statusErr.Code == http.StatusNotFound {
+		// The playlist disappears once the channel goes offline.
+		r.ended = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.interval = media.TargetDuration / 2
+	if r.interval < minPollInterval {
+		r.interval = minPollInterval
+	}
+	for _, segment := range media.Segments {
+		if segment.Number <= r.last {
+			continue
+		}
+		r.last = segment.Number
+		r.updated = r.polled
+		if r.skipAds && segment.Ad {
+			continue
+		}
+		r.queue = append(r.queue, segment)
+	}
+	if media.Ended {
+		r.ended = true
+	}
+	if len(r.queue) == 0 && time.Since(r.updated) > staleTargetDurations*media.TargetDuration {
+		r.ended = true
+	}
+	return nil
+}
+
+// Close stops the recording.
+func (r *Recorder) Close() error {
+	if r.err == nil {
+		r.err = errMergerClosed
+	}
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return errors.WithStack(err)
+}
+
+// Chunks returns the number of chunks found so far.
+func (r *Recorder) Chunks() int {
+	return r.recorded + len(r.queue)
+}
+
+// Current returns the number of chunks already recorded.
+func (r *Recorder) Current() int {
+	return r.recorded
+}
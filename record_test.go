@@ -0,0 +1,157 @@
+package twitchdl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUsher serves a live media playlist whose window moves forward on every request.
+type fakeUsher struct {
+	mu       sync.Mutex
+	polls    int
+	windows  [][]int
+	endlist  bool
+	segments map[string]int
+	// ads holds the numbers of the segments that are ads.
+	ads map[int]bool
+}
+
+func (u *fakeUsher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if strings.HasSuffix(r.URL.Path, ".ts") {
+		u.segments[r.URL.Path]++
+		fmt.Fprintf(w, "[%s]", strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".ts"))
+		return
+	}
+	if u.polls >= len(u.windows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	window := u.windows[u.polls]
+	u.polls++
+	fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:0\n#EXT-X-MEDIA-SEQUENCE:%d\n", window[0])
+	for _, n := range window {
+		title := "live"
+		if u.ads[n] {
+			title = "Amazon|1"
+		}
+		fmt.Fprintf(w, "#EXTINF:2.000,%s\n%d.ts\n", title, n)
+	}
+	if u.endlist && u.polls == len(u.windows) {
+		fmt.Fprintf(w, "#EXT-X-ENDLIST\n")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	defer func(d time.Duration) { minPollInterval = d }(minPollInterval)
+	minPollInterval = time.Millisecond
+
+	tcs := []struct {
+		name    string
+		endlist bool
+	}{
+		{name: "endlist", endlist: true},
+		{name: "offline", endlist: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			usher := &fakeUsher{
+				windows:  [][]int{{0, 1, 2}, {1, 2, 3}, {3, 4, 5}},
+				endlist:  tc.endlist,
+				segments: map[string]int{},
+			}
+			srv := httptest.NewServer(usher)
+			defer srv.Close()
+
+			r := newRecorder(context.Background(), srv.Client(), srv.URL+"/index-live.m3u8", newOptions(nil))
+			b, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "[0][1][2][3][4][5]", string(b))
+			assert.Equal(t, 6, r.Current())
+			for path, count := range usher.segments {
+				assert.Equal(t, 1, count, path)
+			}
+		})
+	}
+}
+
+func TestRecorder_Canceled(t *testing.T) {
+	usher := &fakeUsher{windows: [][]int{{0}}, segments: map[string]int{}}
+	srv := httptest.NewServer(usher)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := newRecorder(ctx, srv.Client(), srv.URL+"/index-live.m3u8", newOptions(nil))
+	_, err := ioutil.ReadAll(r)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRecorder_SkipAds(t *testing.T) {
+	defer func(d time.Duration) { minPollInterval = d }(minPollInterval)
+	minPollInterval = time.Millisecond
+
+	usher := &fakeUsher{
+		windows:  [][]int{{0, 1, 2}, {2, 3, 4}},
+		endlist:  true,
+		segments: map[string]int{},
+		ads:      map[int]bool{1: true, 2: true},
+	}
+	srv := httptest.NewServer(usher)
+	defer srv.Close()
+
+	r := newRecorder(context.Background(), srv.Client(), srv.URL+"/index-live.m3u8", newOptions([]Option{WithSkipAds()}))
+	b, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "[0][3][4]", string(b))
+	assert.Zero(t, usher.segments["/1.ts"])
+}
+
+func TestRecord(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/gql":
+			fmt.Fprint(w, `{"data":{"streamPlaybackAccessToken":{"value":"token","signature":"sig"}}}`)
+		case r.URL.Path == "/api/channel/hls/name.m3u8":
+			fmt.Fprint(w, "#EXTM3U\n"+
+				`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p60 (source)"`+"\n"+
+				`#EXT-X-STREAM-INF:BANDWIDTH=6000000,RESOLUTION=1920x1080,VIDEO="chunked",FRAME-RATE=60.000`+"\n"+
+				"https://video.example.com/chunked.m3u8\n"+
+				`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="720p30",NAME="720p"`+"\n"+
+				`#EXT-X-STREAM-INF:BANDWIDTH=2000000,RESOLUTION=1280x720,VIDEO="720p30",FRAME-RATE=30.000`+"\n"+
+				"https://video.example.com/720p30.m3u8\n")
+		case strings.HasSuffix(r.URL.Path, ".ts"):
+			fmt.Fprintf(w, "[%s]", strings.TrimPrefix(r.URL.Path, "/"))
+		default:
+			fmt.Fprintf(w, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXTINF:2.000,live\n%s.ts\n#EXT-X-ENDLIST\n", strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".m3u8"))
+		}
+	}))
+	defer srv.Close()
+
+	qualities, err := Qualities_live(context.Background(), testClient(t, srv), "id", "name")
+	require.NoError(t, err)
+	for _, tc := range []struct{ expr, body string }{
+		{"best", "[chunked.ts]"},
+		{"<=720p", "[720p30.ts]"},
+		{"480p,worst", "[720p30.ts]"},
+	} {
+		q, err := SelectQuality(qualities, tc.expr)
+		require.NoError(t, err)
+		recording, err := Record(context.Background(), testClient(t, srv), "id", "name", q)
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(recording)
+		require.NoError(t, err)
+		assert.Equal(t, tc.body, string(b), tc.expr)
+	}
+}
@@ -0,0 +1,75 @@
+package remux
+
+import (
+	"github.com/pkg/errors"
+)
+
+// samplingFrequencies maps the sampling frequency indexes of ADTS headers to Hz.
+var samplingFrequencies = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// adtsHeader is the header of an ADTS frame.
+//
+// https://wiki.multimedia.cx/index.php/ADTS
+type adtsHeader struct {
+	objectType     int
+	frequencyIndex int
+	channels       int
+	headerLength   int
+	frameLength    int
+}
+
+// samplesPerFrame is the number of PCM samples encoded in an AAC frame.
+const samplesPerFrame = 1024
+
+func parseADTS(b []byte) (adtsHeader, error) {
+	if len(b) < 7 || b[0] != 0xFF || b[1]&0xF0 != 0xF0 {
+		return adtsHeader{}, errors.New("invalid ADTS header")
+	}
+	h := adtsHeader{
+		objectType:     int(b[2]>>6) + 1,
+		frequencyIndex: int(b[2] >> 2 & 0x0F),
+		channels:       int(b[2]&0x01)<<2 | int(b[3]>>6),
+		headerLength:   7,
+		frameLength:    int(b[3]&0x03)<<11 | int(b[4])<<3 | int(b[5]>>5),
+	}
+	if b[1]&0x01 == 0 {
+		// The header is followed by a CRC.
+		h.headerLength = 9
+	}
+	if h.frequencyIndex >= len(samplingFrequencies) {
+		return adtsHeader{}, errors.New("invalid ADTS sampling frequency")
+	}
+	if h.frameLength < h.headerLength {
+		return adtsHeader{}, errors.New("invalid ADTS frame length")
+	}
+	return h, nil
+}
+
+func (h adtsHeader) sampleRate() int {
+	return samplingFrequencies[h.frequencyIndex]
+}
+
+// audioSpecificConfig returns the MPEG-4 AudioSpecificConfig matching the header.
+func (h adtsHeader) audioSpecificConfig() []byte {
+	return []byte{
+		byte(h.objectType<<3 | h.frequencyIndex>>1),
+		byte(h.frequencyIndex&1<<7 | h.channels<<3),
+	}
+}
+
+// adtsFrames splits b into ADTS frames. A truncated last frame is dropped.
+func adtsFrames(b []byte) (headers []adtsHeader, frames [][]byte, err error) {
+	for len(b) > 0 {
+		h, err := parseADTS(b)
+		if err != nil {
+			return headers, frames, err
+		}
+		if len(b) < h.frameLength {
+			break
+		}
+		headers = append(headers, h)
+		frames = append(frames, b[:h.frameLength])
+		b = b[h.frameLength:]
+	}
+	return headers, frames, nil
+}
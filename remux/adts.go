@@ -0,0 +1,69 @@
+package remux
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ADTSWriter extracts the AAC stream of the MPEG-TS stream written to it
+// as raw ADTS frames written to w. The video stream, if any, is dropped.
+type ADTSWriter struct {
+	w      io.Writer
+	demux  *demuxer
+	frames int
+	err    error
+}
+
+// NewADTSWriter returns an ADTSWriter writing the ADTS frames to w.
+func NewADTSWriter(w io.Writer) *ADTSWriter {
+	a := &ADTSWriter{w: w}
+	a.demux = newDemuxer(a.pes)
+	return a
+}
+
+// Write demuxes p. It implements io.Writer.
+func (a *ADTSWriter) Write(p []byte) (int, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+	if err := a.demux.write(p); err != nil {
+		a.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes the last pending frames.
+// It does not close the underlying io.Writer.
+func (a *ADTSWriter) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if err := a.demux.flush(); err != nil {
+		a.err = err
+		return err
+	}
+	a.err = errors.New("remux: ADTSWriter closed")
+	if a.frames == 0 {
+		return errors.New("no audio found")
+	}
+	return nil
+}
+
+func (a *ADTSWriter) pes(streamType byte, p pes) error {
+	if streamType != streamTypeAAC {
+		return nil
+	}
+	_, frames, err := adtsFrames(p.data)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		if _, err := a.w.Write(frame); err != nil {
+			return errors.WithStack(err)
+		}
+		a.frames++
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package remux
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// H.264 NAL unit types.
+const (
+	nalSlice = 1
+	nalIDR   = 5
+	nalSPS   = 7
+	nalPPS   = 8
+	nalAUD   = 9
+)
+
+// nalus splits an Annex B byte stream into NAL units.
+func nalus(b []byte) [][]byte {
+	var units [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); {
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			i++
+			continue
+		}
+		if start >= 0 {
+			units = append(units, trimZeros(b[start:i]))
+		}
+		i += 3
+		start = i
+	}
+	if start >= 0 && start < len(b) {
+		units = append(units, b[start:])
+	}
+	return units
+}
+
+// trimZeros removes the trailing zero bytes preceding a 4 bytes start code.
+func trimZeros(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// avcSample converts an access unit to the length prefixed format used by MP4.
+// The parameter sets and access unit delimiters are stripped.
+func avcSample(units [][]byte) (sample []byte, key bool) {
+	for _, u := range units {
+		if len(u) == 0 {
+			continue
+		}
+		switch u[0] & 0x1f {
+		case nalSPS, nalPPS, nalAUD:
+			continue
+		case nalIDR:
+			key = true
+		}
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(u)))
+		sample = append(sample, size[:]...)
+		sample = append(sample, u...)
+	}
+	return sample, key
+}
+
+// reference reports whether the access unit is used as a reference by other
+// frames, according to the nal_ref_idc of its slices.
+func reference(units [][]byte) bool {
+	for _, u := range units {
+		if len(u) == 0 {
+			continue
+		}
+		if typ := u[0] & 0x1f; (typ == nalSlice || typ == nalIDR) && u[0]&0x60 != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// bitReader reads the RBSP of a NAL unit.
+type bitReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+// rbsp removes the emulation prevention bytes of a NAL unit.
+func rbsp(nalu []byte) []byte {
+	out := make([]byte, 0, len(nalu))
+	zeros := 0
+	for _, c := range nalu {
+		if zeros >= 2 && c == 3 {
+			zeros = 0
+			continue
+		}
+		if c == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (r *bitReader) u(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.b)*8 {
+			r.err = errors.New("truncated SPS")
+			return 0
+		}
+		v = v<<1 | uint32(r.b[r.pos/8]>>(7-uint(r.pos%8))&1)
+		r.pos++
+	}
+	return v
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.u(1) == 0 && r.err == nil {
+		zeros++
+		if zeros > 31 {
+			r.err = errors.New("invalid Exp-Golomb code")
+			return 0
+		}
+	}
+	return (1<<uint(zeros) - 1) + r.u(zeros)
+}
+
+// se reads a signed Exp-Golomb code.
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v&1 == 1 {
+		return int32(v/2 + 1)
+	}
+	return -int32(v / 2)
+}
+
+// spsResolution returns the size of the pictures described by a Sequence Parameter Set.
+//
+// https://www.itu.int/rec/T-REC-H.264 7.3.2.1.1
+func spsResolution(sps []byte) (width, height int, err error) {
+	r := &bitReader{b: rbsp(sps)}
+	r.u(8) // NAL header
+	profile := r.u(8)
+	r.u(16) // constraint flags and level
+	r.ue()  // seq_parameter_set_id
+	chroma := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chroma = r.ue()
+		if chroma == 3 {
+			r.u(1) // separate_colour_plane_flag
+		}
+		r.ue() // bit_depth_luma_minus8
+		r.ue() // bit_depth_chroma_minus8
+		r.u(1) // qpprime_y_zero_transform_bypass_flag
+		if r.u(1) == 1 {
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.u(1) == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := int32(8), int32(8)
+				for j := 0; j < size; j++ {
+					if next != 0 {
+						next = (last + r.se() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.u(1) // delta_pic_order_always_zero_flag
+		r.se() // offset_for_non_ref_pic
+		r.se() // offset_for_top_to_bottom_field
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se() // offset_for_ref_frame
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.u(1) // gaps_in_frame_num_value_allowed_flag
+	widthInMbs := int(r.ue()) + 1
+	heightInMapUnits := int(r.ue()) + 1
+	frameMbsOnly := int(r.u(1))
+	if frameMbsOnly == 0 {
+		r.u(1) // mb_adaptive_frame_field_flag
+	}
+	r.u(1) // direct_8x8_inference_flag
+	var cropLeft, cropRight, cropTop, cropBottom int
+	if r.u(1) == 1 {
+		cropLeft, cropRight = int(r.ue()), int(r.ue())
+		cropTop, cropBottom = int(r.ue()), int(r.ue())
+	}
+	if r.err != nil {
+		return 0, 0, r.err
+	}
+
+	cropUnitX, cropUnitY := 1, 2-frameMbsOnly
+	switch chroma {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*(2-frameMbsOnly)
+	case 2:
+		cropUnitX = 2
+	}
+	width = widthInMbs*16 - (cropLeft+cropRight)*cropUnitX
+	height = (2-frameMbsOnly)*heightInMapUnits*16 - (cropTop+cropBottom)*cropUnitY
+	return width, height, nil
+}
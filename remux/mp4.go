@@ -0,0 +1,194 @@
+package remux
+
+import (
+	"encoding/binary"
+)
+
+// ISO/IEC 14496-12 boxes.
+//
+// https://developer.apple.com/documentation/quicktime-file-format
+
+func u8(v uint8) []byte { return []byte{v} }
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+func zeros(n int) []byte { return make([]byte, n) }
+
+func concat(parts ...[]byte) []byte {
+	var n int
+	for _, p := range parts {
+		n += len(p)
+	}
+	b := make([]byte, 0, n)
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	content := concat(payload...)
+	return concat(u32(uint32(8+len(content))), []byte(typ), content)
+}
+
+func fullBox(typ string, version uint8, flags uint32, payload ...[]byte) []byte {
+	return box(typ, append([][]byte{u32(uint32(version)<<24 | flags&0xFFFFFF)}, payload...)...)
+}
+
+// matrix is the unity transformation matrix.
+var matrix = concat(u32(0x00010000), u32(0), u32(0), u32(0), u32(0x00010000), u32(0), u32(0), u32(0), u32(0x40000000))
+
+// ftyp returns the file type box with the major brand "brand".
+func ftyp(brand string) []byte {
+	return box("ftyp", []byte(brand), u32(0x200), []byte("isom"), []byte("iso5"), []byte("iso6"), []byte("mp41"), []byte(brand))
+}
+
+func moov(tracks []*track) []byte {
+	mvhd := fullBox("mvhd", 0, 0,
+		u32(0), u32(0), // creation and modification time
+		u32(1000), u32(0), // timescale and duration
+		u32(0x00010000), u16(0x0100), zeros(10), // rate, volume and reserved
+		matrix, zeros(24),
+		u32(uint32(len(tracks)+1)), // next track ID
+	)
+	var traks, trexs []byte
+	for _, t := range tracks {
+		traks = append(traks, trak(t)...)
+		trexs = append(trexs, fullBox("trex", 0, 0, u32(t.id), u32(1), u32(0), u32(0), u32(0))...)
+	}
+	return box("moov", mvhd, traks, box("mvex", trexs))
+}
+
+func trak(t *track) []byte {
+	var volume uint16
+	var width, height uint32
+	if t.video {
+		width, height = uint32(t.width)<<16, uint32(t.height)<<16
+	} else {
+		volume = 0x0100
+	}
+	tkhd := fullBox("tkhd", 0, 0x3,
+		u32(0), u32(0), u32(t.id), u32(0), u32(0), // times, track ID, reserved and duration
+		zeros(8), u16(0), u16(0), u16(volume), u16(0), // reserved, layer, alternate group, volume and reserved
+		matrix, u32(width), u32(height),
+	)
+	mdhd := fullBox("mdhd", 0, 0, u32(0), u32(0), u32(t.timescale), u32(0), u16(0x55C4), u16(0)) // language "und"
+	var hdlr, mhd []byte
+	if t.video {
+		hdlr = fullBox("hdlr", 0, 0, u32(0), []byte("vide"), zeros(12), []byte("VideoHandler\x00"))
+		mhd = fullBox("vmhd", 0, 1, zeros(8))
+	} else {
+		hdlr = fullBox("hdlr", 0, 0, u32(0), []byte("soun"), zeros(12), []byte("SoundHandler\x00"))
+		mhd = fullBox("smhd", 0, 0, zeros(4))
+	}
+	dinf := box("dinf", fullBox("dref", 0, 0, u32(1), fullBox("url ", 0, 1)))
+	stbl := box("stbl",
+		fullBox("stsd", 0, 0, u32(1), sampleEntry(t)),
+		fullBox("stts", 0, 0, u32(0)),
+		fullBox("stsc", 0, 0, u32(0)),
+		fullBox("stsz", 0, 0, u32(0), u32(0)),
+		fullBox("stco", 0, 0, u32(0)),
+	)
+	return box("trak", tkhd, box("mdia", mdhd, hdlr, box("minf", mhd, dinf, stbl)))
+}
+
+func sampleEntry(t *track) []byte {
+	if t.video {
+		avcC := box("avcC",
+			u8(1), t.sps[1:4], u8(0xFF), // version, profile, compatibility, level and 4 bytes NAL lengths
+			u8(0xE1), u16(uint16(len(t.sps))), t.sps,
+			u8(1), u16(uint16(len(t.pps))), t.pps,
+		)
+		return box("avc1",
+			zeros(6), u16(1), // reserved and data reference index
+			zeros(16), u16(uint16(t.width)), u16(uint16(t.height)),
+			u32(0x00480000), u32(0x00480000), u32(0), u16(1), // resolution, reserved and frame count
+			zeros(32), u16(0x0018), u16(0xFFFF), // compressor name, depth and pre-defined
+			avcC,
+		)
+	}
+	dsi := concat(u8(0x05), u8(uint8(len(t.asc))), t.asc)
+	dcd := concat(u8(0x04), u8(uint8(13+len(dsi))), u8(0x40), u8(0x15), zeros(3), u32(0), u32(0), dsi)
+	sl := []byte{0x06, 0x01, 0x02}
+	es := concat(u8(0x03), u8(uint8(3+len(dcd)+len(sl))), u16(0), u8(0), dcd, sl)
+	return box("mp4a",
+		zeros(6), u16(1), // reserved and data reference index
+		zeros(8), u16(uint16(t.channels)), u16(16), zeros(4), u32(uint32(t.sampleRate)<<16),
+		fullBox("esds", 0, 0, es),
+	)
+}
+
+// Sample flags.
+const (
+	flagsSync    = 0x02000000
+	flagsNonSync = 0x01010000
+)
+
+// moof returns the movie fragment box of the pending samples of tracks.
+// The samples data are expected to follow in a mdat box in the order of tracks.
+func moof(sequence uint32, tracks []*track) []byte {
+	build := func(moofSize int) []byte {
+		offset := moofSize + 8
+		var trafs []byte
+		for _, t := range tracks {
+			if len(t.samples) == 0 {
+				continue
+			}
+			entries := make([]byte, 0, 16*len(t.samples))
+			size := 0
+			for _, s := range t.samples {
+				flags := uint32(flagsSync)
+				if t.video && !s.key {
+					flags = flagsNonSync
+				}
+				cto := s.pts - s.dts
+				if cto < 0 {
+					cto = 0
+				}
+				entries = append(entries, concat(u32(s.duration), u32(uint32(len(s.data))), u32(flags), u32(uint32(cto)))...)
+				size += len(s.data)
+			}
+			trafs = append(trafs, box("traf",
+				fullBox("tfhd", 0, 0x020000, u32(t.id)), // default-base-is-moof
+				fullBox("tfdt", 1, 0, u64(uint64(t.samples[0].dts))),
+				fullBox("trun", 0, 0x000F01, u32(uint32(len(t.samples))), u32(uint32(offset)), entries),
+			)...)
+			offset += size
+		}
+		return box("moof", fullBox("mfhd", 0, 0, u32(sequence)), trafs)
+	}
+	return build(len(build(0)))
+}
+
+// fragmentRef locates a movie fragment for the mfra box.
+type fragmentRef struct {
+	time   uint64
+	offset uint64
+}
+
+// mfra returns the movie fragment random access box of the track "id".
+func mfra(id uint32, refs []fragmentRef) []byte {
+	entries := make([]byte, 0, 19*len(refs))
+	for _, r := range refs {
+		entries = append(entries, concat(u64(r.time), u64(r.offset), u8(1), u8(1), u8(1))...)
+	}
+	tfra := fullBox("tfra", 1, 0, u32(id), u32(0), u32(uint32(len(refs))), entries)
+	size := 8 + len(tfra) + 16
+	return box("mfra", tfra, fullBox("mfro", 0, 0, u32(uint32(size))))
+}
@@ -0,0 +1,549 @@
+// Package remux converts an MPEG-TS stream carrying H.264 video and AAC audio
+// into a fragmented MP4 file, or extracts its audio as M4A or ADTS AAC,
+// without any external dependency.
+package remux
+
+import (
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timescale is the MPEG-TS clock rate, used as the timescale of the video track.
+const timescale = 90000
+
+// sample is an audio or video frame.
+// dts and pts are expressed in the timescale of the track.
+type sample struct {
+	data     []byte
+	dts, pts int64
+	duration uint32
+	key      bool
+}
+
+// track holds the codec configuration and the pending samples of a stream.
+type track struct {
+	id        uint32
+	video     bool
+	timescale uint32
+
+	// H.264
+	sps, pps      []byte
+	width, height int
+	// AAC
+	asc        []byte
+	channels   int
+	sampleRate int
+	// next is the decode time of the next audio frame.
+	next    int64
+	started bool
+
+	samples []sample
+	// lastTS is the last unwrapped timestamp of the stream in 90kHz.
+	lastTS int64
+	// shift is added to the timestamps to remove the discontinuities.
+	shift int64
+	// timeline is added to the timestamps to get their position in the
+	// stream, which keeps the forward jumps, such as around dropped chunks,
+	// but not the backward jumps.
+	timeline int64
+	// step is the last gap between two timestamps in 90kHz.
+	step int64
+	// crossed is the number of discontinuities of the Writer crossed by the stream.
+	crossed int
+}
+
+// discontinuityGap is the gap in 90kHz between two timestamps of a stream
+// above which the stream is considered discontinuous, such as between
+// the concatenated sections of a VOD.
+const discontinuityGap = timescale
+
+// discontinuity is a jump of the timestamps shared by the audio and video streams.
+type discontinuity struct {
+	// to is the first unwrapped timestamp in 90kHz after the jump.
+	to int64
+	// shift and timeline are the shift and timeline of the streams after the jump.
+	shift, timeline int64
+}
+
+// continuous returns ts, the next unwrapped timestamp of t, shifted so that
+// the stream never jumps backward, nor forward if the Writer is concatenated.
+func (r *Writer) continuous(t *track, ts int64) int64 {
+	if t.lastTS >= 0 {
+		gap := ts - t.lastTS
+		if gap < 0 || r.concatenated && gap > discontinuityGap {
+			d := r.discontinuity(t, ts)
+			t.shift, t.timeline = d.shift, d.timeline
+		} else if gap > 0 && gap <= discontinuityGap {
+			t.step = gap
+		}
+	}
+	t.lastTS = ts
+	return ts + t.shift
+}
+
+// discontinuity returns the discontinuity of the jump of t to ts.
+// The first stream crossing a discontinuity computes its shift and the other
+// stream reuses it when it jumps to the same timestamps, so that both streams
+// stay in sync.
+func (r *Writer) discontinuity(t *track, ts int64) discontinuity {
+	for i := t.crossed; i < len(r.discontinuities); i++ {
+		if d := r.discontinuities[i]; d.to-discontinuityGap <= ts && ts <= d.to+discontinuityGap {
+			t.crossed = i + 1
+			return d
+		}
+	}
+	delta := t.lastTS + t.step - ts
+	d := discontinuity{to: ts, shift: t.shift + delta, timeline: t.timeline}
+	if ts < t.lastTS {
+		d.timeline += delta
+	}
+	r.discontinuities = append(r.discontinuities, d)
+	t.crossed = len(r.discontinuities)
+	return d
+}
+
+func (t *track) configured() bool {
+	if t.video {
+		return t.sps != nil && t.pps != nil
+	}
+	return t.asc != nil
+}
+
+// duration returns the duration of the pending samples in the timescale of the track.
+func (t *track) duration() int64 {
+	if len(t.samples) == 0 {
+		return 0
+	}
+	last := t.samples[len(t.samples)-1]
+	return last.dts + int64(last.duration) - t.samples[0].dts
+}
+
+// Writer remuxes the MPEG-TS stream written to it into a fragmented MP4 written to w.
+// A fragment starts at every video keyframe or every two seconds of audio if
+// there is no video. The backward jumps of the timestamps are always removed
+// and the forward jumps are removed too if the Writer is Concatenated.
+// Close must be called to flush the last fragment.
+type Writer struct {
+	w     io.Writer
+	brand string
+	demux *demuxer
+	// audioOnly drops the video stream.
+	audioOnly bool
+
+	video, audio *track
+	tracks       []*track
+	// base is the timestamp in 90kHz mapped to the start of the MP4.
+	base int64
+	// first is the first timestamp of the stream in 90kHz.
+	first int64
+	// first and start are positions in the timeline of the streams.
+	// start is the position mapped to base.
+	start int64
+	// end is the timestamp in 90kHz of the end of the last written sample.
+	end int64
+	// trimStart and trimEnd are relative to first.
+	// trimEnd is negative if the stream is not trimmed.
+	trimStart, trimEnd int64
+	// ended is true once the video frames following trimEnd are reached.
+	ended bool
+	// concatenated enables the removal of the forward jumps.
+	concatenated    bool
+	discontinuities []discontinuity
+
+	header   bool
+	sequence uint32
+	written  int64
+	refs     []fragmentRef
+	err      error
+}
+
+// NewWriter returns a Writer remuxing into an MP4 written to w.
+func NewWriter(w io.Writer) *Writer {
+	r := &Writer{
+		w:     w,
+		brand: "mp42",
+		video: &track{video: true, timescale: timescale, lastTS: -1},
+		audio: &track{lastTS: -1},
+		base:  -1,
+		first: -1,
+
+		trimEnd: -1,
+	}
+	r.demux = newDemuxer(r.pes)
+	return r
+}
+
+// NewAudioWriter returns a Writer extracting the audio stream into an M4A written to w.
+// The video stream, if any, is dropped.
+func NewAudioWriter(w io.Writer) *Writer {
+	r := NewWriter(w)
+	r.brand = "M4A "
+	r.audioOnly = true
+	return r
+}
+
+// Concatenated removes the forward jumps of the timestamps of the stream too,
+// such as between the concatenated sections of a VOD or around dropped chunks.
+// Both streams are shifted by the same amount at each jump.
+// Concatenated must be called before Write.
+func (r *Writer) Concatenated() {
+	r.concatenated = true
+}
+
+// Trim drops the frames before the last video keyframe at or before start,
+// or before the audio frame containing start if there is no video, and the
+// frames presented at or after end. The MP4 still starts at zero.
+// start and end are relative to the first timestamp of the stream and
+// end is ignored if zero. The forward jumps of the timestamps removed by
+// Concatenated, such as around dropped chunks, still count.
+// Trim must be called before Write.
+func (r *Writer) Trim(start, end time.Duration) {
+	r.trimStart = ticks(start)
+	r.trimEnd = -1
+	if end > 0 {
+		r.trimEnd = ticks(end)
+	}
+}
+
+// Range returns the interval of the stream written to the MP4,
+// relative to the first timestamp of the stream as in Trim. end is start plus
+// the duration of the MP4, up to the end of the last presented frame, which
+// excludes the jumps removed after start.
+// It is only accurate once the Writer is closed.
+func (r *Writer) Range() (start, end time.Duration) {
+	if r.base < 0 || r.end < r.base {
+		return 0, 0
+	}
+	return duration(r.start - r.first), duration(r.start - r.first + r.end - r.base)
+}
+
+func ticks(d time.Duration) int64 {
+	return int64(d) * timescale / int64(time.Second)
+}
+
+func duration(ticks int64) time.Duration {
+	return time.Duration(ticks * int64(time.Second) / timescale)
+}
+
+// Write demuxes p. It implements io.Writer.
+func (r *Writer) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if err := r.demux.write(p); err != nil {
+		r.err = err
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close flushes the pending samples and writes the index of the fragments.
+// It does not close the underlying io.Writer.
+func (r *Writer) Close() error {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.demux.flush(); err != nil {
+		r.err = err
+		return err
+	}
+	if n := len(r.video.samples); n > 0 {
+		// The duration of the last frame is unknown.
+		r.video.samples[n-1].duration = 3000
+		if n > 1 {
+			r.video.samples[n-1].duration = r.video.samples[n-2].duration
+		}
+	}
+	if err := r.flush(-1); err != nil {
+		r.err = err
+		return err
+	}
+	if !r.header {
+		r.err = errors.New("no audio or video found")
+		return r.err
+	}
+	r.err = errors.New("remux: Writer closed")
+	if len(r.refs) == 0 {
+		return nil
+	}
+	_, err := r.w.Write(mfra(r.tracks[0].id, r.refs))
+	return errors.WithStack(err)
+}
+
+// hasVideo reports whether the stream carries video, according to the PMT.
+func (r *Writer) hasVideo() bool {
+	return !r.audioOnly && r.demux.types[streamTypeH264]
+}
+
+func (r *Writer) pes(streamType byte, p pes) error {
+	if !p.hasPTS {
+		return nil
+	}
+	switch streamType {
+	case streamTypeH264:
+		if r.audioOnly {
+			return nil
+		}
+		return r.videoPES(p)
+	case streamTypeAAC:
+		return r.audioPES(p)
+	}
+	return nil
+}
+
+func (r *Writer) videoPES(p pes) error {
+	t := r.video
+	raw := unwrap(p.dts, t.lastTS)
+	dts := r.continuous(t, raw)
+	pts := dts + unwrap(p.pts, raw) - raw
+
+	units := nalus(p.data)
+	for _, u := range units {
+		if len(u) == 0 {
+			continue
+		}
+		switch u[0] & 0x1f {
+		case nalSPS:
+			if t.sps == nil {
+				width, height, err := spsResolution(u)
+				if err != nil {
+					return err
+				}
+				t.sps = append([]byte{}, u...)
+				t.width, t.height = width, height
+			}
+		case nalPPS:
+			if t.pps == nil {
+				t.pps = append([]byte{}, u...)
+			}
+		}
+	}
+	data, key := avcSample(units)
+	if len(data) == 0 {
+		return nil
+	}
+	pos := raw + t.timeline
+	if r.first < 0 {
+		r.first = pos
+	}
+	if r.ended {
+		return nil
+	}
+	if r.trimEnd >= 0 && pos+pts-dts-r.first >= r.trimEnd {
+		// The frame is presented after trimEnd. The following frames are dropped
+		// too once they cannot be presented before trimEnd or if they may
+		// depend on this frame.
+		r.ended = pos-r.first >= r.trimEnd || reference(units)
+		return nil
+	}
+	if key && t.configured() && (r.base < 0 || pos-r.first <= r.trimStart) {
+		// The MP4 starts at the last keyframe at or before trimStart.
+		r.rebase(dts)
+		r.start = pos
+	}
+	if r.base < 0 || dts < r.base {
+		return nil
+	}
+
+	if n := len(t.samples); n > 0 {
+		t.samples[n-1].duration = uint32(dts - r.base - t.samples[n-1].dts)
+		if key {
+			if err := r.flush(dts); err != nil {
+				return err
+			}
+		}
+	}
+	t.samples = append(t.samples, sample{data: data, dts: dts - r.base, pts: pts - r.base, key: key})
+	return nil
+}
+
+// rebase drops the pending samples decoded before the 90kHz timestamp base,
+// which becomes the start of the MP4.
+func (r *Writer) rebase(base int64) {
+	if r.base < 0 {
+		r.base = base
+		return
+	}
+	r.video.samples = nil
+	if a := r.audio; a.started {
+		delta := (base - r.base) * int64(a.sampleRate) / timescale
+		var samples []sample
+		for _, s := range a.samples {
+			if s.dts >= delta {
+				s.dts -= delta
+				s.pts -= delta
+				samples = append(samples, s)
+			}
+		}
+		a.samples = samples
+		a.next -= delta
+		if a.next < 0 {
+			a.started = false
+		}
+	}
+	r.base = base
+}
+
+func (r *Writer) audioPES(p pes) error {
+	t := r.audio
+	raw := unwrap(p.pts, t.lastTS)
+	pts := r.continuous(t, raw)
+	if r.first < 0 && !r.hasVideo() {
+		r.first = raw + t.timeline
+	}
+
+	headers, frames, err := adtsFrames(p.data)
+	if err != nil {
+		return err
+	}
+	for i, frame := range frames {
+		h := headers[i]
+		if t.asc == nil {
+			t.asc = h.audioSpecificConfig()
+			t.channels = h.channels
+			t.sampleRate = h.sampleRate()
+			t.timescale = uint32(t.sampleRate)
+		}
+		frameTicks := samplesPerFrame * timescale / int64(t.sampleRate)
+		ts := pts + int64(i)*frameTicks
+		pos := raw + t.timeline + int64(i)*frameTicks
+		if r.trimEnd >= 0 && r.first >= 0 && pos-r.first >= r.trimEnd {
+			continue
+		}
+		if r.base < 0 {
+			if r.hasVideo() {
+				// Wait for the first video keyframe.
+				continue
+			}
+			if pos+frameTicks-r.first <= r.trimStart {
+				continue
+			}
+			r.base, r.start = ts, pos
+		}
+		if ts < r.base {
+			continue
+		}
+		if !t.started {
+			t.next = (ts - r.base) * int64(t.sampleRate) / timescale
+			t.started = true
+		}
+		t.samples = append(t.samples, sample{
+			data:     frame[h.headerLength:],
+			dts:      t.next,
+			pts:      t.next,
+			duration: samplesPerFrame,
+			key:      true,
+		})
+		t.next += samplesPerFrame
+	}
+	if !r.hasVideo() && t.duration() >= 2*int64(t.sampleRate) {
+		return r.flush(-1)
+	}
+	return nil
+}
+
+// flush writes the pending video samples and the audio samples decoded before
+// the 90kHz timestamp "until" as a movie fragment.
+// All the pending samples are written if until is negative.
+func (r *Writer) flush(until int64) error {
+	if !r.header {
+		if err := r.writeHeader(until < 0); err != nil {
+			return err
+		}
+		if !r.header {
+			return nil
+		}
+	}
+
+	var pendingAudio []sample
+	if until >= 0 && r.audio.id != 0 {
+		limit := (until - r.base) * int64(r.audio.sampleRate) / timescale
+		for i, s := range r.audio.samples {
+			if s.dts >= limit {
+				pendingAudio = append([]sample{}, r.audio.samples[i:]...)
+				r.audio.samples = r.audio.samples[:i]
+				break
+			}
+		}
+	}
+
+	var tracks []*track
+	var size int
+	for _, t := range r.tracks {
+		for _, s := range t.samples {
+			size += len(s.data)
+		}
+		if len(t.samples) > 0 {
+			tracks = append(tracks, t)
+		}
+	}
+	if len(tracks) > 0 {
+		r.sequence++
+		first := r.tracks[0]
+		if len(first.samples) > 0 {
+			r.refs = append(r.refs, fragmentRef{time: uint64(first.samples[0].dts), offset: uint64(r.written)})
+		}
+		if err := r.write(moof(r.sequence, tracks)); err != nil {
+			return err
+		}
+		if err := r.write(concat(u32(uint32(8+size)), []byte("mdat"))); err != nil {
+			return err
+		}
+		for _, t := range tracks {
+			var last int64
+			for _, s := range t.samples {
+				if err := r.write(s.data); err != nil {
+					return err
+				}
+				if e := s.pts + int64(s.duration); e > last {
+					last = e
+				}
+			}
+			end := r.base + last*timescale/int64(t.timescale)
+			if end > r.end {
+				r.end = end
+			}
+			t.samples = nil
+		}
+	}
+	r.audio.samples = append(r.audio.samples, pendingAudio...)
+	return nil
+}
+
+// writeHeader writes the ftyp and moov boxes once the codecs of the streams are known.
+// The streams that are still not configured are dropped if final is true.
+func (r *Writer) writeHeader(final bool) error {
+	if !final && (r.hasVideo() && !r.video.configured() || r.demux.types[streamTypeAAC] && !r.audio.configured()) {
+		return nil
+	}
+	var tracks []*track
+	if r.video.configured() {
+		tracks = append(tracks, r.video)
+	}
+	if r.audio.configured() {
+		tracks = append(tracks, r.audio)
+	}
+	if len(tracks) == 0 {
+		return nil
+	}
+	for i, t := range tracks {
+		t.id = uint32(i + 1)
+	}
+	r.tracks = tracks
+	if err := r.write(ftyp(r.brand)); err != nil {
+		return err
+	}
+	if err := r.write(moov(tracks)); err != nil {
+		return err
+	}
+	r.header = true
+	return nil
+}
+
+func (r *Writer) write(b []byte) error {
+	n, err := r.w.Write(b)
+	r.written += int64(n)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,547 @@
+package remux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bitWriter writes the fields of a Sequence Parameter Set.
+type bitWriter struct {
+	b    []byte
+	bits int
+}
+
+func (w *bitWriter) u(n int, v uint32) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		w.b[len(w.b)-1] |= byte(v>>uint(i)&1) << uint(7-w.bits%8)
+		w.bits++
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	n := 0
+	for x := v; x > 1; x >>= 1 {
+		n++
+	}
+	w.u(n, 0)
+	w.u(n+1, v)
+}
+
+// testSPS returns a main profile SPS of a 1920x1080 stream.
+func testSPS() []byte {
+	w := &bitWriter{}
+	w.u(8, 0x67)
+	w.u(8, 77) // profile
+	w.u(8, 0)
+	w.u(8, 40) // level
+	w.ue(0)    // seq_parameter_set_id
+	w.ue(0)    // log2_max_frame_num_minus4
+	w.ue(0)    // pic_order_cnt_type
+	w.ue(0)    // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(1)    // max_num_ref_frames
+	w.u(1, 0)  // gaps_in_frame_num_value_allowed_flag
+	w.ue(119)  // pic_width_in_mbs_minus1
+	w.ue(67)   // pic_height_in_map_units_minus1
+	w.u(1, 1)  // frame_mbs_only_flag
+	w.u(1, 1)  // direct_8x8_inference_flag
+	w.u(1, 1)  // frame_cropping_flag
+	w.ue(0)
+	w.ue(0)
+	w.ue(0)
+	w.ue(4)
+	w.u(1, 0) // vui_parameters_present_flag
+	w.u(1, 1) // rbsp_stop_one_bit
+	return w.b
+}
+
+var testPPS = []byte{0x68, 0xCE, 0x3C, 0x80}
+
+func TestSPSResolution(t *testing.T) {
+	width, height, err := spsResolution(testSPS())
+	require.NoError(t, err)
+	assert.Equal(t, 1920, width)
+	assert.Equal(t, 1080, height)
+}
+
+func TestUnwrap(t *testing.T) {
+	const wrap = int64(1) << 33
+	assert.Equal(t, int64(10), unwrap(10, -1))
+	assert.Equal(t, wrap+10, unwrap(10, wrap-100))
+	assert.Equal(t, wrap-100, unwrap(wrap-100, wrap+10))
+	assert.Equal(t, int64(3000), unwrap(3000, 0))
+}
+
+// tsMuxer packetizes PES packets into a MPEG-TS stream.
+type tsMuxer struct {
+	bytes.Buffer
+	counters map[uint16]byte
+}
+
+const (
+	testVideoPID = 0x100
+	testAudioPID = 0x101
+)
+
+func (m *tsMuxer) packets(pid uint16, pusi bool, payload []byte) {
+	for first := true; len(payload) > 0; first = false {
+		packet := []byte{syncByte, byte(pid >> 8 & 0x1f), byte(pid), 0x10 | m.counters[pid]&0x0f}
+		if first && pusi {
+			packet[1] |= 0x40
+		}
+		m.counters[pid]++
+		n := len(payload)
+		if n > packetSize-4 {
+			n = packetSize - 4
+		}
+		if stuffing := packetSize - 4 - n; stuffing > 0 {
+			packet[3] |= 0x20
+			af := make([]byte, stuffing)
+			af[0] = byte(stuffing - 1)
+			if stuffing > 1 {
+				af[1] = 0
+				for i := 2; i < stuffing; i++ {
+					af[i] = 0xFF
+				}
+			}
+			packet = append(packet, af...)
+		}
+		packet = append(packet, payload[:n]...)
+		payload = payload[n:]
+		m.Write(packet)
+	}
+}
+
+func (m *tsMuxer) tables() {
+	pat := []byte{0, 0x00, 0xB0, 13, 0, 1, 0xC1, 0, 0, 0, 1, 0xF0, 0x00, 0, 0, 0, 0}
+	m.packets(0, true, pat)
+	pmt := []byte{0, 0x02, 0xB0, 23, 0, 1, 0xC1, 0, 0, 0xE1, 0x00, 0xF0, 0x00,
+		streamTypeH264, 0xE1, 0x00, 0xF0, 0x00,
+		streamTypeAAC, 0xE1, 0x01, 0xF0, 0x00,
+		0, 0, 0, 0}
+	m.packets(0x1000, true, pmt)
+}
+
+func encodeTimestamp(marker byte, ts int64) []byte {
+	return []byte{
+		marker<<4 | byte(ts>>29&0x0E) | 1,
+		byte(ts >> 22),
+		byte(ts>>14) | 1,
+		byte(ts >> 7),
+		byte(ts<<1) | 1,
+	}
+}
+
+func (m *tsMuxer) pes(pid uint16, streamID byte, pts, dts int64, data []byte) {
+	header := []byte{0, 0, 1, streamID, 0, 0, 0x80, 0xC0, 10}
+	header = append(header, encodeTimestamp(3, pts)...)
+	header = append(header, encodeTimestamp(1, dts)...)
+	m.packets(pid, true, append(header, data...))
+}
+
+func adtsFrame(payload []byte) []byte {
+	length := 7 + len(payload)
+	// AAC LC, 48kHz, 2 channels.
+	h := []byte{0xFF, 0xF1, 0x4C, 0x80 | byte(length>>11&0x03), byte(length >> 3), byte(length<<5) | 0x1F, 0xFC}
+	return append(h, payload...)
+}
+
+// testStream returns 3 GOPs of 30 frames at 30fps with AAC audio.
+func testStream(t *testing.T, start int64) []byte {
+	m := &tsMuxer{counters: map[uint16]byte{}}
+	m.tables()
+	startCode := []byte{0, 0, 0, 1}
+	audioTS := start
+	for i := int64(0); i < 90; i++ {
+		dts := start + i*3000
+		var au []byte
+		au = append(au, startCode...)
+		au = append(au, 0x09, 0xF0)
+		if i%30 == 0 {
+			au = append(au, startCode...)
+			au = append(au, testSPS()...)
+			au = append(au, startCode...)
+			au = append(au, testPPS...)
+			au = append(au, startCode...)
+			au = append(au, 0x65, 0x88, byte(i))
+		} else {
+			au = append(au, startCode...)
+			au = append(au, 0x41, 0x9A, byte(i))
+		}
+		m.pes(testVideoPID, 0xE0, dts+3000, dts, au)
+		for audioTS <= dts {
+			m.pes(testAudioPID, 0xC0, audioTS, audioTS, adtsFrame([]byte{0x21, byte(audioTS)}))
+			audioTS += samplesPerFrame * timescale / 48000
+		}
+	}
+	return m.Bytes()
+}
+
+type testBox struct {
+	typ     string
+	payload []byte
+}
+
+func boxes(t *testing.T, b []byte) []testBox {
+	var list []testBox
+	for len(b) > 0 {
+		require.True(t, len(b) >= 8)
+		size := int(binary.BigEndian.Uint32(b))
+		require.True(t, size >= 8 && size <= len(b), "invalid box size %d", size)
+		list = append(list, testBox{string(b[4:8]), b[8:size]})
+		b = b[size:]
+	}
+	return list
+}
+
+func child(t *testing.T, b []byte, path ...string) []byte {
+	for _, typ := range path {
+		var found bool
+		for _, c := range boxes(t, b) {
+			if c.typ == typ {
+				b, found = c.payload, true
+				break
+			}
+		}
+		require.True(t, found, "box %s not found", typ)
+	}
+	return b
+}
+
+func TestWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	stream := testStream(t, 900000)
+	// Write in small chunks to split packets between calls.
+	for len(stream) > 0 {
+		n := 1000
+		if n > len(stream) {
+			n = len(stream)
+		}
+		_, err := w.Write(stream[:n])
+		require.NoError(t, err)
+		stream = stream[n:]
+	}
+	require.NoError(t, w.Close())
+
+	var types []string
+	var videoSamples, audioSamples int
+	var firstVideoDecodeTime = uint64(1)
+	top := boxes(t, out.Bytes())
+	for _, b := range top {
+		types = append(types, b.typ)
+		if b.typ != "moof" {
+			continue
+		}
+		for _, traf := range boxes(t, b.payload) {
+			if traf.typ != "traf" {
+				continue
+			}
+			tfhd := child(t, traf.payload, "tfhd")
+			tfdt := child(t, traf.payload, "tfdt")
+			trun := child(t, traf.payload, "trun")
+			count := int(binary.BigEndian.Uint32(trun[4:]))
+			switch binary.BigEndian.Uint32(tfhd[4:]) {
+			case 1:
+				if videoSamples == 0 {
+					firstVideoDecodeTime = binary.BigEndian.Uint64(tfdt[4:])
+				}
+				videoSamples += count
+			case 2:
+				audioSamples += count
+			}
+		}
+	}
+	assert.Equal(t, []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat", "moof", "mdat", "mfra"}, types)
+	assert.Equal(t, 90, videoSamples)
+	assert.True(t, audioSamples >= 80, "%d audio samples", audioSamples)
+	assert.Equal(t, uint64(0), firstVideoDecodeTime)
+
+	avc1 := child(t, top[1].payload, "trak", "mdia", "minf", "stbl", "stsd")[8:]
+	avc1 = child(t, avc1, "avc1")
+	assert.Equal(t, uint16(1920), binary.BigEndian.Uint16(avc1[24:]))
+	assert.Equal(t, uint16(1080), binary.BigEndian.Uint16(avc1[26:]))
+}
+
+func TestWriter_NoStream(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{})
+	_, err := w.Write(make([]byte, packetSize))
+	require.NoError(t, err)
+	assert.Error(t, w.Close())
+}
+
+func TestAudioWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewAudioWriter(&out)
+	_, err := w.Write(testStream(t, 0))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	top := boxes(t, out.Bytes())
+	require.True(t, len(top) > 3)
+	assert.Equal(t, "ftyp", top[0].typ)
+	assert.Equal(t, "M4A ", string(top[0].payload[:4]))
+	assert.Equal(t, "moov", top[1].typ)
+	var traks int
+	for _, b := range boxes(t, top[1].payload) {
+		if b.typ == "trak" {
+			traks++
+		}
+	}
+	assert.Equal(t, 1, traks)
+	stsd := child(t, top[1].payload, "trak", "mdia", "minf", "stbl", "stsd")[8:]
+	child(t, stsd, "mp4a")
+
+	var samples int
+	for _, b := range top {
+		if b.typ == "moof" {
+			trun := child(t, b.payload, "traf", "trun")
+			samples += int(binary.BigEndian.Uint32(trun[4:]))
+		}
+	}
+	// An audio frame every 1920 ticks until the last video frame at 267000.
+	assert.Equal(t, 140, samples)
+}
+
+func TestADTSWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewADTSWriter(&out)
+	_, err := w.Write(testStream(t, 0))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	headers, _, err := adtsFrames(out.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 140, len(headers))
+	assert.Equal(t, 48000, headers[0].sampleRate())
+	assert.Equal(t, 2, headers[0].channels)
+}
+
+// samples returns the number of samples of each track of the MP4 and the
+// decode time of the first fragment of each track.
+func samples(t *testing.T, mp4 []byte) (counts map[uint32]int, starts map[uint32]uint64) {
+	counts, starts = map[uint32]int{}, map[uint32]uint64{}
+	for _, b := range boxes(t, mp4) {
+		if b.typ != "moof" {
+			continue
+		}
+		for _, traf := range boxes(t, b.payload) {
+			if traf.typ != "traf" {
+				continue
+			}
+			id := binary.BigEndian.Uint32(child(t, traf.payload, "tfhd")[4:])
+			if _, ok := starts[id]; !ok {
+				starts[id] = binary.BigEndian.Uint64(child(t, traf.payload, "tfdt")[4:])
+			}
+			counts[id] += int(binary.BigEndian.Uint32(child(t, traf.payload, "trun")[4:]))
+		}
+	}
+	return counts, starts
+}
+
+// durations returns the duration of each sample of the track "id" of the MP4.
+func durations(t *testing.T, mp4 []byte, id uint32) []uint32 {
+	var list []uint32
+	for _, b := range boxes(t, mp4) {
+		if b.typ != "moof" {
+			continue
+		}
+		for _, traf := range boxes(t, b.payload) {
+			if traf.typ != "traf" || binary.BigEndian.Uint32(child(t, traf.payload, "tfhd")[4:]) != id {
+				continue
+			}
+			trun := child(t, traf.payload, "trun")
+			count := int(binary.BigEndian.Uint32(trun[4:]))
+			for i := 0; i < count; i++ {
+				list = append(list, binary.BigEndian.Uint32(trun[12+16*i:]))
+			}
+		}
+	}
+	return list
+}
+
+func TestWriter_Trim(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.Trim(1500*time.Millisecond, 2500*time.Millisecond)
+	_, err := w.Write(testStream(t, 900000))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	counts, starts := samples(t, out.Bytes())
+	// From the keyframe at 1s to the last frame presented before 2.5s,
+	// which is decoded 3000 ticks earlier.
+	assert.Equal(t, 44, counts[1])
+	// Audio frames from 90240 to 224640.
+	assert.Equal(t, 71, counts[2])
+	assert.Equal(t, uint64(0), starts[1])
+	assert.Equal(t, uint64(240*48000/timescale), starts[2])
+
+	start, end := w.Range()
+	assert.Equal(t, time.Second, start)
+	// The end of the last audio frame.
+	assert.Equal(t, duration(224640+1920), end)
+}
+
+func TestAudioWriter_Trim(t *testing.T) {
+	var out bytes.Buffer
+	w := NewAudioWriter(&out)
+	w.Trim(time.Second, 2*time.Second)
+	_, err := w.Write(testStream(t, 0))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	counts, starts := samples(t, out.Bytes())
+	// From the frame at 88320 containing 1s to the frame at 178560.
+	assert.Equal(t, 48, counts[1])
+	assert.Equal(t, uint64(0), starts[1])
+
+	start, end := w.Range()
+	assert.Equal(t, duration(88320), start)
+	assert.Equal(t, duration(180480), end)
+}
+
+func TestWriter_Discontinuity(t *testing.T) {
+	tcs := []struct {
+		name          string
+		first, second int64
+	}{
+		{name: "forward", first: 0, second: 3600 * timescale},
+		{name: "backward", first: 3600 * timescale, second: 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewWriter(&out)
+			w.Concatenated()
+			_, err := w.Write(testStream(t, tc.first))
+			require.NoError(t, err)
+			_, err = w.Write(testStream(t, tc.second))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			counts, _ := samples(t, out.Bytes())
+			assert.Equal(t, 180, counts[1])
+			start, end := w.Range()
+			assert.Equal(t, time.Duration(0), start)
+			// The last frame is presented 3000 ticks after it is decoded.
+			assert.Equal(t, duration(6*timescale+3000), end)
+		})
+	}
+}
+
+func TestWriter_BackwardJump(t *testing.T) {
+	// The timestamps restart without the Writer being Concatenated.
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	_, err := w.Write(testStream(t, 3600*timescale))
+	require.NoError(t, err)
+	_, err = w.Write(testStream(t, 0))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	counts, _ := samples(t, out.Bytes())
+	assert.Equal(t, 180, counts[1])
+	assert.True(t, counts[2] >= 2*80, "%d audio samples", counts[2])
+	for _, d := range durations(t, out.Bytes(), 1) {
+		assert.Equal(t, uint32(3000), d)
+	}
+	_, end := w.Range()
+	assert.Equal(t, duration(6*timescale+3000), end)
+}
+
+func TestWriter_Continuous(t *testing.T) {
+	jump := int64(3600 * timescale)
+	for _, concatenated := range []bool{false, true} {
+		w := NewWriter(&bytes.Buffer{})
+		if concatenated {
+			w.Concatenated()
+		}
+		// The video and audio streams of testStream end at different timestamps.
+		for ts := int64(0); ts <= 267000; ts += 3000 {
+			w.continuous(w.video, ts)
+		}
+		for ts := int64(0); ts <= 266880; ts += 1920 {
+			w.continuous(w.audio, ts)
+		}
+		video := w.continuous(w.video, jump)
+		audio := w.continuous(w.audio, jump+1920)
+		if !concatenated {
+			assert.Equal(t, jump, video)
+			assert.Equal(t, jump+1920, audio)
+			continue
+		}
+		// Both streams are shifted by the shift of the video, which crossed the jump first.
+		assert.Equal(t, int64(270000), video)
+		assert.Equal(t, int64(270000+1920), audio)
+	}
+}
+
+func TestWriter_TrimConcatenated(t *testing.T) {
+	// The chunks between 3s and 10s were dropped.
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.Concatenated()
+	w.Trim(0, 11*time.Second)
+	_, err := w.Write(testStream(t, 0))
+	require.NoError(t, err)
+	_, err = w.Write(testStream(t, 10*timescale))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	counts, _ := samples(t, out.Bytes())
+	// The frames from 0 to 3s and the frames presented from 10s to 11s.
+	assert.Equal(t, 119, counts[1])
+	start, end := w.Range()
+	assert.Equal(t, time.Duration(0), start)
+	assert.Equal(t, 4*time.Second, end)
+}
+
+func TestWriter_TrimBFrames(t *testing.T) {
+	m := &tsMuxer{counters: map[uint16]byte{}}
+	m.tables()
+	startCode := []byte{0, 0, 0, 1}
+	// Frames in decode order: I1 P4 B2 B3 P7 B5 B6, presented every 3000 ticks.
+	frames := []struct {
+		pts    int64
+		header byte
+	}{
+		{3000, 0x65}, {12000, 0x41}, {6000, 0x01}, {9000, 0x01},
+		{21000, 0x41}, {15000, 0x01}, {18000, 0x01},
+	}
+	for i, f := range frames {
+		var au []byte
+		au = append(au, startCode...)
+		au = append(au, 0x09, 0xF0)
+		if i == 0 {
+			au = append(au, startCode...)
+			au = append(au, testSPS()...)
+			au = append(au, startCode...)
+			au = append(au, testPPS...)
+		}
+		au = append(au, startCode...)
+		au = append(au, f.header, 0x88, byte(i))
+		m.pes(testVideoPID, 0xE0, f.pts, int64(i)*3000, au)
+	}
+
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.Trim(0, duration(13000))
+	_, err := w.Write(m.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	counts, _ := samples(t, out.Bytes())
+	// P7 is presented after the end and B5 and B6 may depend on it.
+	assert.Equal(t, 4, counts[1])
+	_, end := w.Range()
+	assert.Equal(t, duration(15000), end)
+}
@@ -0,0 +1,231 @@
+package remux
+
+import (
+	"github.com/pkg/errors"
+)
+
+// MPEG-TS stream types.
+//
+// https://en.wikipedia.org/wiki/Program-specific_information#Elementary_stream_types
+const (
+	streamTypeAAC  = 0x0F
+	streamTypeH264 = 0x1B
+)
+
+const (
+	packetSize = 188
+	syncByte   = 0x47
+)
+
+// pes is a Packetized Elementary Stream packet.
+type pes struct {
+	pts, dts int64
+	hasPTS   bool
+	data     []byte
+}
+
+// pesStream reassembles the PES packets of an elementary stream.
+type pesStream struct {
+	streamType byte
+	buf        []byte
+	started    bool
+}
+
+// demuxer extracts the H.264 and AAC PES packets from an MPEG-TS stream.
+//
+// https://en.wikipedia.org/wiki/MPEG_transport_stream
+type demuxer struct {
+	buf     []byte
+	pmtPID  int
+	streams map[uint16]*pesStream
+	// types holds the stream types declared by the PMT.
+	types map[byte]bool
+	onPES func(streamType byte, p pes) error
+}
+
+func newDemuxer(onPES func(streamType byte, p pes) error) *demuxer {
+	return &demuxer{pmtPID: -1, streams: map[uint16]*pesStream{}, types: map[byte]bool{}, onPES: onPES}
+}
+
+// write demuxes p. Incomplete packets are kept until the next call.
+func (d *demuxer) write(p []byte) error {
+	d.buf = append(d.buf, p...)
+	i := 0
+	for len(d.buf)-i >= packetSize {
+		if d.buf[i] != syncByte {
+			// Resynchronize on the next sync byte.
+			i++
+			continue
+		}
+		if err := d.packet(d.buf[i : i+packetSize]); err != nil {
+			return err
+		}
+		i += packetSize
+	}
+	d.buf = append(d.buf[:0], d.buf[i:]...)
+	return nil
+}
+
+// flush emits the PES packets still being reassembled.
+func (d *demuxer) flush() error {
+	for _, s := range d.streams {
+		if err := d.emit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *demuxer) packet(b []byte) error {
+	pusi := b[1]&0x40 != 0
+	pid := uint16(b[1]&0x1f)<<8 | uint16(b[2])
+	afc := (b[3] >> 4) & 0x3
+	if afc&0x1 == 0 {
+		// No payload.
+		return nil
+	}
+	offset := 4
+	if afc&0x2 != 0 {
+		offset += 1 + int(b[4])
+	}
+	if offset >= len(b) {
+		return nil
+	}
+	payload := b[offset:]
+
+	switch {
+	case pid == 0:
+		if pusi {
+			d.pat(payload)
+		}
+		return nil
+	case int(pid) == d.pmtPID:
+		if pusi {
+			d.pmt(payload)
+		}
+		return nil
+	}
+
+	s, ok := d.streams[pid]
+	if !ok {
+		return nil
+	}
+	if pusi {
+		if err := d.emit(s); err != nil {
+			return err
+		}
+		s.started = true
+	}
+	if s.started {
+		s.buf = append(s.buf, payload...)
+	}
+	return nil
+}
+
+// section returns the content of the PSI section starting in payload, without its CRC.
+func section(payload []byte) []byte {
+	if len(payload) < 1 {
+		return nil
+	}
+	pointer := int(payload[0])
+	payload = payload[1:]
+	if len(payload) < pointer+3 {
+		return nil
+	}
+	payload = payload[pointer:]
+	length := int(payload[1]&0x0f)<<8 | int(payload[2])
+	if length < 4 || len(payload) < 3+length {
+		return nil
+	}
+	return payload[3 : 3+length-4]
+}
+
+// pat parses the Program Association Table to find the PID of the PMT.
+func (d *demuxer) pat(payload []byte) {
+	s := section(payload)
+	if len(s) < 5 {
+		return
+	}
+	for p := s[5:]; len(p) >= 4; p = p[4:] {
+		program := uint16(p[0])<<8 | uint16(p[1])
+		if program == 0 {
+			continue
+		}
+		d.pmtPID = int(p[2]&0x1f)<<8 | int(p[3])
+		return
+	}
+}
+
+// pmt parses the Program Map Table to find the PIDs of the elementary streams.
+func (d *demuxer) pmt(payload []byte) {
+	s := section(payload)
+	if len(s) < 9 {
+		return
+	}
+	infoLength := int(s[7]&0x0f)<<8 | int(s[8])
+	if len(s) < 9+infoLength {
+		return
+	}
+	for p := s[9+infoLength:]; len(p) >= 5; {
+		streamType := p[0]
+		pid := uint16(p[1]&0x1f)<<8 | uint16(p[2])
+		esLength := int(p[3]&0x0f)<<8 | int(p[4])
+		if streamType == streamTypeAAC || streamType == streamTypeH264 {
+			d.types[streamType] = true
+			if _, ok := d.streams[pid]; !ok {
+				d.streams[pid] = &pesStream{streamType: streamType}
+			}
+		}
+		if len(p) < 5+esLength {
+			return
+		}
+		p = p[5+esLength:]
+	}
+}
+
+// emit parses the PES packet reassembled by s.
+func (d *demuxer) emit(s *pesStream) error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	b := s.buf
+	s.buf = nil
+	if len(b) < 9 || b[0] != 0 || b[1] != 0 || b[2] != 1 {
+		return errors.New("invalid PES packet")
+	}
+	flags := b[7]
+	headerLength := int(b[8])
+	if len(b) < 9+headerLength {
+		return errors.New("truncated PES header")
+	}
+	p := pes{data: b[9+headerLength:]}
+	if flags&0x80 != 0 && headerLength >= 5 {
+		p.pts = timestamp(b[9:14])
+		p.dts = p.pts
+		p.hasPTS = true
+	}
+	if flags&0x40 != 0 && headerLength >= 10 {
+		p.dts = timestamp(b[14:19])
+	}
+	return d.onPES(s.streamType, p)
+}
+
+// timestamp decodes a 33 bits PTS or DTS.
+func timestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+// unwrap returns the 33 bits timestamp ts extended to be the closest to last.
+// last is negative if there is no previous timestamp.
+func unwrap(ts, last int64) int64 {
+	const wrap = int64(1) << 33
+	if last < 0 {
+		return ts
+	}
+	n := last - ts + wrap/2
+	k := n / wrap
+	if n < 0 && n%wrap != 0 {
+		k--
+	}
+	return ts + k*wrap
+}
@@ -0,0 +1,109 @@
+package twitchdl
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy describes how failed chunk downloads are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts per chunk, including the first one.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. It doubles after every attempt.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+	// Retryable reports whether an error is worth retrying.
+	// IsRetryable is used when nil.
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy is the RetryPolicy used when none is provided.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	MinBackoff:  time.Second,
+	MaxBackoff:  30 * time.Second,
+}
+
+// backoff returns the delay to wait after the attempt number "attempt" failed.
+// Half of the delay is randomized to avoid retrying all chunks at the same time.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// StatusError is returned when a chunk request responds with a non 2xx status code.
+type StatusError struct {
+	Code int
+	URL  string
+	// RetryAfter is the delay requested by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.URL)
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	err := &StatusError{Code: resp.StatusCode, URL: resp.Request.URL.String()}
+	if s, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && s > 0 {
+		err.RetryAfter = time.Duration(s) * time.Second
+	}
+	return err
+}
+
+// IsRetryable reports whether err is a transient error: a 5xx or 429 status code,
+// a timeout, a connection reset or a truncated response body.
+func IsRetryable(err error) bool {
+	cause := errors.Cause(err)
+	for {
+		switch e := cause.(type) {
+		case *StatusError:
+			return e.Code == http.StatusTooManyRequests || e.Code >= 500
+		case *url.Error:
+			cause = e.Err
+			continue
+		case *net.OpError:
+			if e.Timeout() {
+				return true
+			}
+			cause = e.Err
+			continue
+		case *os.SyscallError:
+			cause = e.Err
+			continue
+		case syscall.Errno:
+			return e == syscall.ECONNRESET || e == syscall.ECONNABORTED || e == syscall.EPIPE
+		case net.Error:
+			return e.Timeout()
+		}
+		break
+	}
+	return cause == io.EOF || cause == io.ErrUnexpectedEOF
+}
@@ -0,0 +1,103 @@
+package twitchdl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tcs := []struct {
+		err      error
+		expected bool
+	}{
+		{err: &StatusError{Code: 500}, expected: true},
+		{err: &StatusError{Code: 503}, expected: true},
+		{err: &StatusError{Code: 429}, expected: true},
+		{err: &StatusError{Code: 404}, expected: false},
+		{err: &StatusError{Code: 403}, expected: false},
+		{err: syscall.ECONNRESET, expected: true},
+		{err: syscall.ENOENT, expected: false},
+		{err: errors.New("other"), expected: false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsRetryable(errors.WithStack(tc.err)))
+		})
+	}
+}
+
+func TestPrepare_Retry(t *testing.T) {
+	tcs := []struct {
+		failures    int
+		status      int
+		attempts    int
+		expectedErr bool
+		expectedReq int
+	}{
+		{failures: 2, status: http.StatusServiceUnavailable, attempts: 3, expectedReq: 3},
+		{failures: 3, status: http.StatusServiceUnavailable, attempts: 3, expectedErr: true, expectedReq: 3},
+		{failures: 1, status: http.StatusTooManyRequests, attempts: 3, expectedReq: 2},
+		{failures: 1, status: http.StatusNotFound, attempts: 3, expectedErr: true, expectedReq: 1},
+	}
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("%d %d", tc.failures, tc.status), func(t *testing.T) {
+			var requests int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tc.failures {
+					w.WriteHeader(tc.status)
+					return
+				}
+				w.Write([]byte("chunk"))
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			require.NoError(t, err)
+			policy := RetryPolicy{MaxAttempts: tc.attempts, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+			body, err := prepare(srv.Client(), req, policy)()
+			assert.Equal(t, tc.expectedReq, requests)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(body)
+			require.NoError(t, err)
+			assert.Equal(t, "chunk", string(b))
+		})
+	}
+}
+
+func TestPrepare_TruncatedBody(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Length", "5")
+		if requests == 1 {
+			w.Write([]byte("ch"))
+			return
+		}
+		w.Write([]byte("chunk"))
+	}))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	policy := RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	body, err := prepare(srv.Client(), req, policy)()
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, "chunk", string(b))
+	assert.Equal(t, 2, requests)
+}
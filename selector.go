@@ -0,0 +1,219 @@
+package twitchdl
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SelectQuality returns the quality of qualities matching the expression expr.
+//
+// expr is a comma separated list of selectors tried in order until one matches:
+//
+//	best              the highest resolution, then framerate, then bandwidth
+//	worst             the lowest resolution, then framerate, then bandwidth
+//	source            the original stream
+//	audio             the audio only quality
+//	<=720p            the best quality of at most 720p. <, >= and > are also
+//	                  supported and a framerate can be added such as <=720p30
+//	max-bandwidth=3M  the best quality with a bandwidth of at most 3Mbps
+//	720p60            the quality named "720p60" or with a height of 720 and 60fps
+//
+// Audio only qualities, subtitles and closed captions are only selected by
+// their name or by "audio".
+func SelectQuality(qualities []Quality, expr string) (Quality, error) {
+	var selectors []selector
+	for _, s := range strings.Split(expr, ",") {
+		sel, err := parseSelector(strings.TrimSpace(s))
+		if err != nil {
+			return Quality{}, err
+		}
+		selectors = append(selectors, sel)
+	}
+	for _, sel := range selectors {
+		if q, ok := sel(qualities); ok {
+			return q, nil
+		}
+	}
+	return Quality{}, errors.Errorf("no quality matches %q", expr)
+}
+
+// selector returns the quality it selects among qualities.
+type selector func(qualities []Quality) (Quality, bool)
+
+func parseSelector(s string) (selector, error) {
+	lower := strings.ToLower(s)
+	switch lower {
+	case "":
+		return nil, errors.New("empty quality selector")
+	case "best":
+		return rank(func(Quality) bool { return true }, true), nil
+	case "worst":
+		return rank(func(Quality) bool { return true }, false), nil
+	case "source":
+		return rank(func(q Quality) bool { return q.Source }, true), nil
+	case "audio":
+		return func(qualities []Quality) (Quality, bool) {
+			for _, q := range qualities {
+				if q.AudioOnly {
+					return q, true
+				}
+			}
+			return Quality{}, false
+		}, nil
+	}
+
+	if strings.HasPrefix(lower, "max-bandwidth=") {
+		max, err := parseBandwidth(s[len("max-bandwidth="):])
+		if err != nil {
+			return nil, err
+		}
+		return rank(func(q Quality) bool { return q.Bandwidth > 0 && q.Bandwidth <= max }, true), nil
+	}
+
+	for _, op := range []string{"<=", ">=", "<", ">"} {
+		if !strings.HasPrefix(s, op) {
+			continue
+		}
+		height, fps, ok := parseResolution(s[len(op):])
+		if !ok {
+			return nil, errors.Errorf("invalid quality selector %q", s)
+		}
+		return rank(func(q Quality) bool {
+			// Qualities are compared by height, then by framerate if it is given.
+			c := compareInts(q.Height, height)
+			if c == 0 && fps > 0 {
+				c = compareInts(int(math.Round(q.FPS)), fps)
+			}
+			switch op {
+			case "<=":
+				return c <= 0
+			case ">=":
+				return c >= 0
+			case "<":
+				return c < 0
+			}
+			return c > 0
+		}, true), nil
+	}
+
+	return func(qualities []Quality) (Quality, bool) {
+		for _, q := range qualities {
+			if strings.EqualFold(q.Name, s) {
+				return q, true
+			}
+		}
+		for _, q := range qualities {
+			// Twitch suffixes the name of the source quality with "(source)".
+			if strings.TrimSpace(strings.TrimSuffix(strings.ToLower(q.Name), "(source)")) == lower {
+				return q, true
+			}
+		}
+		height, fps, ok := parseResolution(s)
+		if !ok {
+			return Quality{}, false
+		}
+		for _, q := range qualities {
+			if video(q) && q.Height == height && (fps == 0 || int(math.Round(q.FPS)) == fps) {
+				return q, true
+			}
+		}
+		return Quality{}, false
+	}, nil
+}
+
+// video reports whether q is a video quality.
+func video(q Quality) bool {
+	return !q.AudioOnly && !strings.HasPrefix(q.Name, SubtitlesPrefix) && !strings.HasPrefix(q.Name, CaptionsPrefix)
+}
+
+// rank returns a selector of the best or the worst video quality matching match.
+// Qualities are ordered by height, then framerate, then bandwidth.
+// The source wins ties.
+func rank(match func(Quality) bool, best bool) selector {
+	return func(qualities []Quality) (Quality, bool) {
+		var candidates []Quality
+		for _, q := range qualities {
+			if video(q) && match(q) {
+				candidates = append(candidates, q)
+			}
+		}
+		if len(candidates) == 0 {
+			return Quality{}, false
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			switch {
+			case a.Height != b.Height:
+				return a.Height < b.Height
+			case a.FPS != b.FPS:
+				return a.FPS < b.FPS
+			case a.Bandwidth != b.Bandwidth:
+				return a.Bandwidth < b.Bandwidth
+			}
+			return !a.Source && b.Source
+		})
+		if best {
+			return candidates[len(candidates)-1], true
+		}
+		return candidates[0], true
+	}
+}
+
+// parseResolution parses "<height>p" or "<height>p<fps>".
+func parseResolution(s string) (height, fps int, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.Index(s, "p")
+	if i <= 0 {
+		return 0, 0, false
+	}
+	height, err := strconv.Atoi(s[:i])
+	if err != nil || height <= 0 {
+		return 0, 0, false
+	}
+	if i+1 == len(s) {
+		return height, 0, true
+	}
+	fps, err = strconv.Atoi(s[i+1:])
+	if err != nil || fps <= 0 {
+		return 0, 0, false
+	}
+	return height, fps, true
+}
+
+// parseBandwidth parses a number of bits per second with an optional K, M or G suffix.
+func parseBandwidth(s string) (int, error) {
+	multiplier := 1.0
+	number := s
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			multiplier = 1e3
+		case 'm', 'M':
+			multiplier = 1e6
+		case 'g', 'G':
+			multiplier = 1e9
+		}
+		if multiplier > 1 {
+			number = s[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil || f <= 0 {
+		return 0, errors.Errorf("invalid bandwidth %q", s)
+	}
+	return int(f * multiplier), nil
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package twitchdl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectQuality(t *testing.T) {
+	vod := []Quality{
+		{Name: "1080p60 (source)", GroupID: "chunked", Height: 1080, FPS: 60, Bandwidth: 6847192, Source: true},
+		{Name: "720p60", GroupID: "720p60", Height: 720, FPS: 60, Bandwidth: 3422999},
+		{Name: "720p", GroupID: "720p30", Height: 720, FPS: 30, Bandwidth: 2303475},
+		{Name: "480p", GroupID: "480p30", Height: 480, FPS: 30, Bandwidth: 1427999},
+		{Name: "Audio Only", GroupID: "audio_only", Bandwidth: 160000, AudioOnly: true},
+		{Name: "subtitles:English", GroupID: "subs"},
+	}
+	clip := []Quality{
+		{Name: "1080p60", Height: 1080, FPS: 60, Source: true},
+		{Name: "720p60", Height: 720, FPS: 60},
+		{Name: "360p30", Height: 360, FPS: 30},
+	}
+	tcs := []struct {
+		expr      string
+		qualities []Quality
+		expected  string
+	}{
+		{"best", vod, "1080p60 (source)"},
+		{"worst", vod, "480p"},
+		{"source", vod, "1080p60 (source)"},
+		{"audio", vod, "Audio Only"},
+		{"Audio Only", vod, "Audio Only"},
+		{"subtitles:English", vod, "subtitles:English"},
+		{"1080p60", vod, "1080p60 (source)"},
+		{"720p30", vod, "720p"},
+		{"720P60", vod, "720p60"},
+		{"<=720p", vod, "720p60"},
+		{"<=720p30", vod, "720p"},
+		{"<720p", vod, "480p"},
+		{">=720p", vod, "1080p60 (source)"},
+		{">1080p,worst", vod, "480p"},
+		{"max-bandwidth=3M", vod, "720p"},
+		{"max-bandwidth=3.5m", vod, "720p60"},
+		{"max-bandwidth=1500k", vod, "480p"},
+		{"900p60, 720p60, best", vod, "720p60"},
+		{"best", clip, "1080p60"},
+		{"worst", clip, "360p30"},
+		{"<=720p", clip, "720p60"},
+		{"max-bandwidth=3M,480p,<=480p", clip, "360p30"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.expr, func(t *testing.T) {
+			q, err := SelectQuality(tc.qualities, tc.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, q.Name)
+		})
+	}
+
+	for _, expr := range []string{"", "best,", "<=abc", "max-bandwidth=x", "max-bandwidth=-1M"} {
+		_, err := SelectQuality(vod, expr)
+		assert.Error(t, err, expr)
+	}
+	_, err := SelectQuality(clip, "audio")
+	assert.Error(t, err)
+	_, err = SelectQuality(vod, "4k")
+	assert.Error(t, err)
+}
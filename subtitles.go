@@ -0,0 +1,148 @@
+package twitchdl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jybp/twitch-downloader/m3u8"
+	"github.com/pkg/errors"
+)
+
+// Prefixes of the names of the qualities returned by Qualities for the subtitles
+// and closed captions renditions of a VOD. Subtitles are downloaded as WebVTT by
+// passing the quality named "subtitles:<name>" to Download. Closed captions are
+// carried inside the video stream and cannot be downloaded separately.
+const (
+	SubtitlesPrefix = "subtitles:"
+	CaptionsPrefix  = "captions:"
+)
+
+// mediaURL returns the URL of the media playlist of master with quality "quality".
+func mediaURL(master m3u8.MasterPlaylist, quality Quality) (string, error) {
+	if strings.HasPrefix(quality.Name, CaptionsPrefix) {
+		return "", errors.Errorf("closed captions %s are carried inside the video stream", strings.TrimPrefix(quality.Name, CaptionsPrefix))
+	}
+	if !strings.HasPrefix(quality.Name, SubtitlesPrefix) {
+		variant, err := findVariant(master, quality)
+		return variant.URL, err
+	}
+	name := strings.TrimPrefix(quality.Name, SubtitlesPrefix)
+	for _, v := range master.Variants {
+		for _, alt := range v.Alternatives {
+			if alt.Type == "SUBTITLES" && alt.Name == name && len(alt.URL) > 0 {
+				return alt.URL, nil
+			}
+		}
+	}
+	return "", errors.Errorf("subtitles %s not found", name)
+}
+
+// isWebVTT reports whether segment is a WebVTT subtitles segment.
+func isWebVTT(segment m3u8.MediaSegment) bool {
+	u, err := url.Parse(segment.URL)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	return ext == ".vtt" || ext == ".webvtt"
+}
+
+var webvttHeaderEnd = regexp.MustCompile(`\r?\n(\r?\n)`)
+
+// webvttCues returns a downloadFunc reading the WebVTT segment of fn without
+// its header so that it can follow the previous segments in a single file.
+func webvttCues(fn downloadFunc) downloadFunc {
+	return func() (io.ReadCloser, error) {
+		body, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if bytes.HasPrefix(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")), []byte("WEBVTT")) {
+			// The header ends with the first blank line. The blank line is
+			// kept to separate the cues from the cues of the previous segment.
+			if loc := webvttHeaderEnd.FindSubmatchIndex(b); loc != nil {
+				b = b[loc[2]:]
+			} else {
+				b = nil
+			}
+		}
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+}
+
+var webvttTimestamp = regexp.MustCompile(`(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})`)
+
+// RebaseWebVTT shifts the cues of the WebVTT file b so that start becomes
+// the beginning of the file, such as the start of a video downloaded from start.
+// The cues ending before start are dropped and the X-TIMESTAMP-MAP header,
+// which maps the cues to the HLS stream, is removed.
+func RebaseWebVTT(b []byte, start time.Duration) []byte {
+	var out bytes.Buffer
+	var block []string
+	header := true
+	flush := func() {
+		defer func() { block, header = nil, false }()
+		for i, line := range block {
+			if header && strings.HasPrefix(line, "X-TIMESTAMP-MAP") {
+				block[i] = ""
+				continue
+			}
+			if header || !strings.Contains(line, "-->") {
+				continue
+			}
+			times := webvttTimestamp.FindAllString(line, 2)
+			if len(times) < 2 || parseWebVTTTimestamp(times[1]) <= start {
+				// The cue ends before start.
+				return
+			}
+			block[i] = webvttTimestamp.ReplaceAllStringFunc(line, func(s string) string {
+				d := parseWebVTTTimestamp(s) - start
+				if d < 0 {
+					d = 0
+				}
+				return formatWebVTTTimestamp(d)
+			})
+			break
+		}
+		for _, line := range block {
+			out.WriteString(line)
+		}
+	}
+	// A block is a header or a cue followed by blank lines.
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		blank := len(strings.TrimRight(line, "\r\n")) == 0
+		if !blank && len(block) > 0 && len(strings.TrimRight(block[len(block)-1], "\r\n")) == 0 {
+			flush()
+		}
+		block = append(block, line)
+	}
+	flush()
+	return out.Bytes()
+}
+
+func parseWebVTTTimestamp(s string) time.Duration {
+	m := webvttTimestamp.FindStringSubmatch(s)
+	var d time.Duration
+	for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second, time.Millisecond} {
+		n, _ := strconv.Atoi(m[i+1])
+		d += time.Duration(n) * unit
+	}
+	return d
+}
+
+func formatWebVTTTimestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", d/time.Hour, d/time.Minute%60, d/time.Second%60, d/time.Millisecond%1000)
+}
@@ -0,0 +1,73 @@
+package twitchdl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubtitles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/gql":
+			fmt.Fprint(w, `{"data":{"videoPlaybackAccessToken":{"value":"token","signature":"sig"}}}`)
+		case strings.HasPrefix(r.URL.Path, "/vod/"):
+			fmt.Fprint(w, "#EXTM3U\n"+
+				`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",URI="https://vod.example.com/subs/en.m3u8"`+"\n"+
+				`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"`+"\n"+
+				`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="chunked",NAME="1080p",AUTOSELECT=YES,DEFAULT=YES`+"\n"+
+				`#EXT-X-STREAM-INF:BANDWIDTH=6847192,VIDEO="chunked",SUBTITLES="subs",CLOSED-CAPTIONS="cc"`+"\n"+
+				"https://vod.example.com/chunked/index-dvr.m3u8\n"+
+				`#EXT-X-MEDIA:TYPE=VIDEO,GROUP-ID="720p30",NAME="720p"`+"\n"+
+				`#EXT-X-STREAM-INF:BANDWIDTH=2303475,VIDEO="720p30",SUBTITLES="subs",CLOSED-CAPTIONS="cc"`+"\n"+
+				"https://vod.example.com/720p30/index-dvr.m3u8\n")
+		case r.URL.Path == "/subs/en.m3u8":
+			fmt.Fprint(w, "#EXTM3U\n#EXT-X-TARGETDURATION:10\n")
+			for i := 0; i < 3; i++ {
+				fmt.Fprintf(w, "#EXTINF:10.000,\n%d.vtt\n", i)
+			}
+			fmt.Fprint(w, "#EXT-X-ENDLIST\n")
+		default:
+			n := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/subs/"), ".vtt")
+			fmt.Fprintf(w, "WEBVTT\r\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\r\n\r\n00:00:%s0.000 --> 00:00:%s5.000\r\ncue %s\r\n", n, n, n)
+		}
+	}))
+	defer srv.Close()
+
+	qualities, err := Qualities(context.Background(), testClient(t, srv), "id", "1")
+	require.NoError(t, err)
+	var names []string
+	for _, q := range qualities {
+		names = append(names, q.Name)
+	}
+	assert.Equal(t, []string{"1080p", "720p", "subtitles:English", "captions:English"}, names)
+
+	download, err := Download(context.Background(), testClient(t, srv), "id", "1", Quality{Name: "subtitles:English"}, 10*time.Second, 0)
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(download)
+	require.NoError(t, err)
+	assert.Equal(t, "WEBVTT\r\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\r\n\r\n"+
+		"00:00:10.000 --> 00:00:15.000\r\ncue 1\r\n\r\n"+
+		"00:00:20.000 --> 00:00:25.000\r\ncue 2\r\n", string(b))
+
+	_, err = Download(context.Background(), testClient(t, srv), "id", "1", Quality{Name: "captions:English"}, 0, 0)
+	assert.Error(t, err)
+}
+
+func TestRebaseWebVTT(t *testing.T) {
+	vtt := "WEBVTT\r\nX-TIMESTAMP-MAP=MPEGTS:900000,LOCAL:00:00:00.000\r\n\r\n" +
+		"1\r\n00:00:05.000 --> 00:00:09.000\r\nbefore\r\n\r\n" +
+		"00:00:09.500 --> 00:00:11.000 align:start\r\noverlapping\r\n\r\n" +
+		"01:00:12.250 --> 01:00:15.000\r\nafter\r\n"
+	assert.Equal(t, "WEBVTT\r\n\r\n"+
+		"00:00:00.000 --> 00:00:01.000 align:start\r\noverlapping\r\n\r\n"+
+		"01:00:02.250 --> 01:00:05.000\r\nafter\r\n", string(RebaseWebVTT([]byte(vtt), 10*time.Second)))
+}
@@ -94,7 +94,7 @@ func TestDownload(t *testing.T) {
 		t.SkipNow()
 	}
 
-	reader, err := twitchdl.Download(context.Background(), client(t), clientID, vodID, quality)
+	reader, err := twitchdl.Download(context.Background(), client(t), clientID, vodID, twitchdl.Quality{Name: quality}, 0, 0)
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
@@ -0,0 +1,87 @@
+package twitch
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// WithOAuthToken returns a copy of c that sends the OAuth token of a user
+// on GQL requests so that the VODs and streams reserved to the subscribers
+// of a channel or the private VODs of the user can be accessed.
+// The "oauth:" and "OAuth " prefixes of token are ignored.
+func (c Client) WithOAuthToken(token string) Client {
+	token = strings.TrimSpace(token)
+	for _, prefix := range []string{"oauth:", "OAuth ", "oauth "} {
+		token = strings.TrimPrefix(token, prefix)
+	}
+	c.oauthToken = token
+	return c
+}
+
+// authorize sets the Authorization header of the GQL request req.
+// It must be called after the request has been dumped so that the
+// token does not end up in error messages.
+func (c *Client) authorize(req *http.Request) {
+	if len(c.oauthToken) > 0 {
+		req.Header.Set("Authorization", "OAuth "+c.oauthToken)
+	}
+}
+
+// AccessDeniedError is returned when twitch refuses to serve a VOD or a stream.
+type AccessDeniedError struct {
+	// Code is the error code returned by twitch such as "vod_manifest_restricted".
+	Code string
+	// Message is the message returned by twitch, if any.
+	Message string
+	// Authenticated is true if an OAuth token was sent.
+	Authenticated bool
+}
+
+func (e *AccessDeniedError) Error() string {
+	msg := "access denied"
+	if len(e.Code) > 0 {
+		msg += " (" + e.Code + ")"
+	}
+	if len(e.Message) > 0 {
+		msg += ": " + e.Message
+	}
+	switch {
+	case e.Code == "invalid_token":
+		return msg + ": the OAuth token is invalid or has expired"
+	case e.Authenticated:
+		return msg + ": the OAuth token does not belong to an account allowed to watch this content"
+	}
+	return msg + ": this content requires the OAuth token of an account allowed to watch it, such as a subscriber of the channel"
+}
+
+// accessDenied returns an *AccessDeniedError if the response with status
+// code s and body b denies the access to the content, nil otherwise.
+func (c *Client) accessDenied(s int, b []byte) error {
+	if s != http.StatusUnauthorized && s != http.StatusForbidden {
+		return nil
+	}
+	err := &AccessDeniedError{Authenticated: len(c.oauthToken) > 0}
+	// Usher responds with a list of errors.
+	var usher []struct {
+		Error     string `json:"error"`
+		ErrorCode string `json:"error_code"`
+	}
+	// GQL responds with a single error.
+	var gql struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(b, &usher) == nil && len(usher) > 0 {
+		err.Code, err.Message = usher[0].ErrorCode, usher[0].Error
+	} else if json.Unmarshal(b, &gql) == nil {
+		err.Message = gql.Message
+		if len(err.Message) == 0 {
+			err.Message = gql.Error
+		}
+		if s == http.StatusUnauthorized {
+			err.Code = "invalid_token"
+		}
+	}
+	return err
+}
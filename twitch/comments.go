@@ -0,0 +1,168 @@
+package twitch
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Comment describes a chat message sent during the live stream of a VOD.
+type Comment struct {
+	ID string
+	// Offset is the position of the comment inside the VOD.
+	Offset    time.Duration
+	CreatedAt time.Time
+	UserID    string
+	UserLogin string
+	UserName  string
+	// UserColor is the color of the user name, e.g. "#FF0000". It is empty if the user never set one.
+	UserColor string
+	Badges    []Badge
+	Fragments []Fragment
+}
+
+// Badge describes a chat badge displayed next to the user name.
+type Badge struct {
+	SetID   string
+	Version string
+}
+
+// Fragment is a part of a comment. It is either text or an emote.
+type Fragment struct {
+	Text string
+	// EmoteID is empty if the fragment is text.
+	EmoteID string
+}
+
+// Message returns the text of the comment.
+func (c Comment) Message() string {
+	var msg strings.Builder
+	for _, f := range c.Fragments {
+		msg.WriteString(f.Text)
+	}
+	return msg.String()
+}
+
+// Comments retrieves a page of the chat replay of the VOD "id".
+// The first page starts at "offset" and cursor must be empty.
+// The following pages are retrieved using the next cursor which is empty on the last page.
+func (c *Client) Comments(ctx context.Context, id string, offset time.Duration, cursor string) (comments []Comment, next string, err error) {
+	query := `query($id: ID!, $offset: Int, $cursor: Cursor) {
+		video(id: $id) {
+			comments(contentOffsetSeconds: $offset, after: $cursor) {
+				edges {
+					cursor
+					node {
+						id
+						contentOffsetSeconds
+						createdAt
+						commenter {
+							id
+							login
+							displayName
+						}
+						message {
+							userColor
+							userBadges {
+								setID
+								version
+							}
+							fragments {
+								text
+								emote {
+									emoteID
+								}
+							}
+						}
+					}
+				}
+				pageInfo {
+					hasNextPage
+				}
+			}
+		}
+	}`
+	variables := map[string]interface{}{"id": id}
+	if len(cursor) > 0 {
+		variables["cursor"] = cursor
+	} else {
+		variables["offset"] = int(offset / time.Second)
+	}
+
+	type respPayload struct {
+		Data struct {
+			Video *struct {
+				Comments struct {
+					Edges []struct {
+						Cursor string `json:"cursor"`
+						Node   struct {
+							ID                   string    `json:"id"`
+							ContentOffsetSeconds float64   `json:"contentOffsetSeconds"`
+							CreatedAt            time.Time `json:"createdAt"`
+							Commenter            *struct {
+								ID          string `json:"id"`
+								Login       string `json:"login"`
+								DisplayName string `json:"displayName"`
+							} `json:"commenter"`
+							Message struct {
+								UserColor  string `json:"userColor"`
+								UserBadges []struct {
+									SetID   string `json:"setID"`
+									Version string `json:"version"`
+								} `json:"userBadges"`
+								Fragments []struct {
+									Text  string `json:"text"`
+									Emote *struct {
+										EmoteID string `json:"emoteID"`
+									} `json:"emote"`
+								} `json:"fragments"`
+							} `json:"message"`
+						} `json:"node"`
+					} `json:"edges"`
+					PageInfo struct {
+						HasNextPage bool `json:"hasNextPage"`
+					} `json:"pageInfo"`
+				} `json:"comments"`
+			} `json:"video"`
+		} `json:"data"`
+	}
+	var p respPayload
+	if err := c.gql(ctx, query, variables, &p); err != nil {
+		return nil, "", err
+	}
+	if p.Data.Video == nil {
+		return nil, "", errors.Errorf("VOD %s not found", id)
+	}
+	for _, edge := range p.Data.Video.Comments.Edges {
+		node := edge.Node
+		comment := Comment{
+			ID:        node.ID,
+			Offset:    time.Duration(node.ContentOffsetSeconds * float64(time.Second)),
+			CreatedAt: node.CreatedAt,
+			UserColor: node.Message.UserColor,
+		}
+		if node.Commenter != nil {
+			comment.UserID = node.Commenter.ID
+			comment.UserLogin = node.Commenter.Login
+			comment.UserName = node.Commenter.DisplayName
+		}
+		for _, b := range node.Message.UserBadges {
+			comment.Badges = append(comment.Badges, Badge{SetID: b.SetID, Version: b.Version})
+		}
+		for _, f := range node.Message.Fragments {
+			fragment := Fragment{Text: f.Text}
+			if f.Emote != nil {
+				fragment.EmoteID = f.Emote.EmoteID
+			}
+			comment.Fragments = append(comment.Fragments, fragment)
+		}
+		comments = append(comments, comment)
+		next = edge.Cursor
+	}
+	if !p.Data.Video.Comments.PageInfo.HasNextPage {
+		next = ""
+	}
+	return comments, next, nil
+}
@@ -0,0 +1,86 @@
+package twitch
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Doer performs HTTP requests. *http.Client implements Doer.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc allows the use of ordinary functions as Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls f(req).
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Doer to alter the requests or the responses of a Client.
+type Middleware func(next Doer) Doer
+
+// chain wraps doer with middlewares.
+// The first middleware is the first one to see the request.
+func chain(doer Doer, middlewares []Middleware) Doer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		doer = middlewares[i](doer)
+	}
+	return doer
+}
+
+// Header returns a Middleware that sets the header "key" to "value" on every request.
+func Header(key, value string) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set(key, value)
+			return next.Do(req)
+		})
+	}
+}
+
+// Logger returns a Middleware that logs every request with its status code and duration.
+func Logger(l *log.Logger) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			from := time.Now()
+			resp, err := next.Do(req)
+			if err != nil {
+				l.Printf("%s %s: %v (%v)", req.Method, req.URL, err, time.Since(from))
+				return resp, err
+			}
+			l.Printf("%s %s: %d (%v)", req.Method, req.URL, resp.StatusCode, time.Since(from))
+			return resp, err
+		})
+	}
+}
+
+// RateLimit returns a Middleware that waits at least "interval" between two requests.
+func RateLimit(interval time.Duration) Middleware {
+	var mu sync.Mutex
+	var next time.Time
+	return func(doer Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			now := time.Now()
+			if next.Before(now) {
+				next = now
+			}
+			at := next
+			next = next.Add(interval)
+			mu.Unlock()
+
+			timer := time.NewTimer(at.Sub(now))
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			return doer.Do(req)
+		})
+	}
+}
@@ -1,16 +1,20 @@
 package twitch
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"path"
 	"regexp"
 	"strings"
+	"time"
+
 	"github.com/pkg/errors"
 )
 
@@ -60,50 +64,91 @@ func ID_Clip(URL string) (string, error) {
 
 // Client manages communication with the twitch API.
 type Client struct {
-	client      *http.Client
+	client      Doer
 	clientID    string
 	apiURL      string
 	usherAPIURL string
+	oauthToken  string
 }
 
 // New returns a new twitch API client.
-func New(client *http.Client, clientID string) Client {
-	return Client{client, clientID, "https://gql.twitch.tv/gql", "http://usher.twitch.tv/"}
+// Every request goes through the middlewares then through client.
+// http.DefaultClient is used if client is nil.
+func New(client *http.Client, clientID string, middlewares ...Middleware) Client {
+	return Custom(client, clientID, "https://gql.twitch.tv/gql", "http://usher.twitch.tv/", middlewares...)
 }
 
 // Custom returns a new twitch API client with custom API endpoints
-func Custom(client *http.Client, clientID, apiURL, usherAPIURL string) Client {
-	return Client{client, clientID, apiURL, usherAPIURL}
+func Custom(client *http.Client, clientID, apiURL, usherAPIURL string, middlewares ...Middleware) Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return Client{client: chain(client, middlewares), clientID: clientID, apiURL: apiURL, usherAPIURL: usherAPIURL}
 }
 
 func (c *Client) vodToken(ctx context.Context, id string) (token, sig string, err error) {
-	gqlPayload := `{"operationName":"PlaybackAccessToken_Template","query":"query PlaybackAccessToken_Template($login: String!, $isLive: Boolean!, $vodID: ID!, $isVod: Boolean!, $playerType: String!) {  streamPlaybackAccessToken(channelName: $login, params: {platform: \"web\", playerBackend: \"mediaplayer\", playerType: $playerType}) @include(if: $isLive) {    value    signature    __typename  }  videoPlaybackAccessToken(id: $vodID, params: {platform: \"web\", playerBackend: \"mediaplayer\", playerType: $playerType}) @include(if: $isVod) {    value    signature    __typename  }}", "variables":{"isLive":false,"login":"","isVod":true,"vodID":"%s","playerType":"site"}}`
+	return c.playbackAccessToken(ctx, id, "")
+}
+
+func (c *Client) streamToken(ctx context.Context, channel string) (token, sig string, err error) {
+	return c.playbackAccessToken(ctx, "", channel)
+}
 
-	body := strings.NewReader(fmt.Sprintf(gqlPayload, id))
+// playbackAccessTokenQuery retrieves the token of a VOD or of a live stream.
+const playbackAccessTokenQuery = `query PlaybackAccessToken_Template($login: String!, $isLive: Boolean!, $vodID: ID!, $isVod: Boolean!, $playerType: String!) {  streamPlaybackAccessToken(channelName: $login, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isLive) {    value    signature    __typename  }  videoPlaybackAccessToken(id: $vodID, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isVod) {    value    signature    __typename  }}`
+
+// playbackAccessToken retrieves the token of the VOD "id" or, if login is not empty,
+// of the live stream of the channel "login".
+func (c *Client) playbackAccessToken(ctx context.Context, id, login string) (token, sig string, err error) {
+	type variables struct {
+		IsLive     bool   `json:"isLive"`
+		Login      string `json:"login"`
+		IsVod      bool   `json:"isVod"`
+		VodID      string `json:"vodID"`
+		PlayerType string `json:"playerType"`
+	}
+	isLive := len(login) > 0
+	b, err := json.Marshal(struct {
+		OperationName string    `json:"operationName"`
+		Query         string    `json:"query"`
+		Variables     variables `json:"variables"`
+	}{"PlaybackAccessToken_Template", playbackAccessTokenQuery, variables{isLive, login, !isLive, id, "site"}})
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	body := bytes.NewReader(b)
 	req, err := http.NewRequest(http.MethodPost, c.apiURL, body)
 	if err != nil {
 		return "", "", errors.WithStack(err)
 	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Client-Id", c.clientID)
 	dump, err := httputil.DumpRequestOut(req, true)
 	if err != nil {
 		return "", "", errors.WithStack(err)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	c.authorize(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", "", errors.Errorf("%v\n%s", err, string(dump))
 	}
 	defer resp.Body.Close()
 	if s := resp.StatusCode; s < 200 || s >= 300 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		if err := c.accessDenied(s, b); err != nil {
+			return "", "", errors.WithStack(err)
+		}
 		return "", "", errors.Errorf("invalid status code %d\n%s", s, string(dump))
 	}
 
+	type accessToken struct {
+		Value     string `json:"value"`
+		Signature string `json:"signature"`
+	}
 	type respPayload struct {
 		Data struct {
-			VideoPlaybackAccessToken struct {
-				Value     string `json:"value"`
-				Signature string `json:"signature"`
-			} `json:"videoPlaybackAccessToken"`
+			VideoPlaybackAccessToken  accessToken `json:"videoPlaybackAccessToken"`
+			StreamPlaybackAccessToken accessToken `json:"streamPlaybackAccessToken"`
 		} `json:"data"`
 	}
 	//var v map[string]interface{}
@@ -113,18 +158,43 @@ func (c *Client) vodToken(ctx context.Context, id string) (token, sig string, er
 		return "", "", errors.Errorf("%v\n%s", err, string(dump))
 	}
 	//fmt.Println(v)
+	if isLive {
+		return p.Data.StreamPlaybackAccessToken.Value, p.Data.StreamPlaybackAccessToken.Signature, nil
+	}
 	return p.Data.VideoPlaybackAccessToken.Value, p.Data.VideoPlaybackAccessToken.Signature, nil
 }
 
-func (c *Client) ClipToken(ctx context.Context, id string) (token, sig string, err error) {
-	gqlPayload :=  `{"operationName":"VideoAccessToken_Clip","query":"title","variables":{"slug":"%s"},"extensions":{"persistedQuery":{"version":1,"sha256Hash":"36b89d2507fce29e5ca551df756d27c1cfe079e2609642b4390aa4c35796eb11"}}}`
+// clipTokenQueryHash identifies the persisted query retrieving the token of a clip.
+const clipTokenQueryHash = "36b89d2507fce29e5ca551df756d27c1cfe079e2609642b4390aa4c35796eb11"
 
-	body := strings.NewReader(fmt.Sprintf(gqlPayload, id))
+// ClipToken retrieves the token and the signature to append to the source URL of the clip "id".
+// The token is query escaped.
+func (c *Client) ClipToken(ctx context.Context, id string) (token, sig string, err error) {
+	type persistedQuery struct {
+		Version    int    `json:"version"`
+		Sha256Hash string `json:"sha256Hash"`
+	}
+	type extensions struct {
+		PersistedQuery persistedQuery `json:"persistedQuery"`
+	}
+	type variables struct {
+		Slug string `json:"slug"`
+	}
+	b, err := json.Marshal(struct {
+		OperationName string     `json:"operationName"`
+		Query         string     `json:"query"`
+		Variables     variables  `json:"variables"`
+		Extensions    extensions `json:"extensions"`
+	}{"VideoAccessToken_Clip", "title", variables{id}, extensions{persistedQuery{1, clipTokenQueryHash}}})
+	if err != nil {
+		return "", "", errors.WithStack(err)
+	}
 
-	req, err := http.NewRequest(http.MethodPost, c.apiURL, body)
+	req, err := http.NewRequest(http.MethodPost, c.apiURL, bytes.NewReader(b))
 	if err != nil {
 		return "", "", errors.WithStack(err)
 	}
+	req = req.WithContext(ctx)
 
 	dump, err := httputil.DumpRequestOut(req, true)
 	if err != nil {
@@ -132,24 +202,29 @@ func (c *Client) ClipToken(ctx context.Context, id string) (token, sig string, e
 	}
 
 	req.Header.Set("Client-Id", c.clientID)
+	c.authorize(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return "", "", errors.Errorf("%v\n%s", err, string(dump))
 	}
 
 	defer resp.Body.Close()
 	if s := resp.StatusCode; s < 200 || s >= 300 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		if err := c.accessDenied(s, b); err != nil {
+			return "", "", errors.WithStack(err)
+		}
 		return "", "", errors.Errorf("invalid status code %d\n%s", s, string(dump))
 	}
 
-	type respPayload struct{
+	type respPayload struct {
 		Data struct {
-			Clip struct {
-				PlayBackAccessToken struct{
+			Clip *struct {
+				PlayBackAccessToken struct {
 					Signature string `json:"signature"`
-					Value string `json:"value"`
-				}`json:"playbackAccessToken"`
+					Value     string `json:"value"`
+				} `json:"playbackAccessToken"`
 			} `json:"clip"`
 		} `json:"data"`
 	}
@@ -158,23 +233,14 @@ func (c *Client) ClipToken(ctx context.Context, id string) (token, sig string, e
 	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
 		return "", "", errors.Errorf("%v\n%s", err, string(dump))
 	}
-
-	value := p.Data.Clip.PlayBackAccessToken.Value
-	temp := strings.Split(value, ",")
-	var clip_uri string
-
-	for i:=0; i<len(temp);i++{
-		if strings.Contains(temp[i], "clip_uri"){
-			clip_uri = temp[i]
-			break
-		}
-	} 
-	
-
-	clip_uri = strings.SplitN(strings.ReplaceAll(clip_uri,"\"", ""),":",2)[1]
-	value = url.QueryEscape(value)
-
-	return value, p.Data.Clip.PlayBackAccessToken.Signature, nil
+	clip := p.Data.Clip
+	if clip == nil {
+		return "", "", errors.Errorf("clip %s not found", id)
+	}
+	if len(clip.PlayBackAccessToken.Value) == 0 {
+		return "", "", errors.Errorf("no access token for clip %s", id)
+	}
+	return url.QueryEscape(clip.PlayBackAccessToken.Value), clip.PlayBackAccessToken.Signature, nil
 }
 
 // M3U8 retrieves the M3U8 file of a specific VOD.
@@ -188,14 +254,66 @@ func (c *Client) M3U8(ctx context.Context, id string) ([]byte, error) {
 	u := fmt.Sprintf("%svod/%s?nauth=%s&nauthsig=%s&allow_audio_only=true&allow_source=true",
 		c.usherAPIURL, id, tok, sig)
 
-	resp, err := c.client.Get(u)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	defer resp.Body.Close()
+	if s := resp.StatusCode; s < 200 || s >= 300 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		if err := c.accessDenied(s, b); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return nil, errors.Errorf("%d\n%s\n%s", s, u, string(b))
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ErrOffline is returned when the channel is not live.
+var ErrOffline = errors.New("channel is offline")
+
+// LiveM3U8 retrieves the M3U8 file of the live stream of a specific channel.
+// ErrOffline is returned if the channel is not live.
+func (c *Client) LiveM3U8(ctx context.Context, channel string) ([]byte, error) {
+	tok, sig, err := c.streamToken(ctx, channel)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(tok) == 0 {
+		return nil, errors.WithStack(ErrOffline)
+	}
+
+	query := url.Values{}
+	query.Set("token", tok)
+	query.Set("sig", sig)
+	query.Set("allow_audio_only", "true")
+	query.Set("allow_source", "true")
+	u := fmt.Sprintf("%sapi/channel/hls/%s.m3u8?%s", c.usherAPIURL, url.PathEscape(strings.ToLower(channel)), query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := c.client.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.WithStack(ErrOffline)
+	}
 	if s := resp.StatusCode; s < 200 || s >= 300 {
 		b, _ := ioutil.ReadAll(resp.Body)
+		if err := c.accessDenied(s, b); err != nil {
+			return nil, errors.WithStack(err)
+		}
 		return nil, errors.Errorf("%d\n%s\n%s", s, u, string(b))
 	}
 
@@ -204,7 +322,35 @@ func (c *Client) M3U8(ctx context.Context, id string) ([]byte, error) {
 
 // VOD describes a twitch VOD.
 type VOD struct {
-	Title string
+	ID            string
+	Title         string
+	Description   string
+	ChannelLogin  string
+	ChannelName   string
+	BroadcastType string
+	CreatedAt     time.Time
+	PublishedAt   time.Time
+	Length        time.Duration
+	ViewCount     int
+	Game          Game
+	// PreviewURL is the URL of the full size thumbnail.
+	PreviewURL string
+	// ThumbnailURLs are the URLs of the thumbnails shown in the video player.
+	ThumbnailURLs []string
+	// MutedSegments are the parts of the VOD muted because of copyrighted audio.
+	MutedSegments []MutedSegment
+}
+
+// MutedSegment describes a muted part of a VOD.
+type MutedSegment struct {
+	Offset   time.Duration
+	Duration time.Duration
+}
+
+// Game describes the game or category of a VOD.
+type Game struct {
+	ID   string
+	Name string
 }
 
 
@@ -218,47 +364,50 @@ type Clip struct {
 
 // VOD retrieves the video informations of a specific VOD.
 func (c *Client) VOD(ctx context.Context, id string) (VOD, error) {
-	gqlPayload := `{"operationName":"VideoMetadata","variables":{"channelLogin":"","videoID":"%s"},"extensions":{"persistedQuery":{"version":1,"sha256Hash":"226edb3e692509f727fd56821f5653c05740242c82b0388883e0c0e75dcbf687"}}}`
-	body := strings.NewReader(fmt.Sprintf(gqlPayload, id))
-	req, err := http.NewRequest(http.MethodPost, c.apiURL, body)
-	if err != nil {
-		return VOD{}, errors.WithStack(err)
-	}
-	dump, err := httputil.DumpRequestOut(req, true)
-
-	if err != nil {
-		return VOD{}, errors.WithStack(err)
-	}
-	req.Header.Set("Client-Id", c.clientID)
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return VOD{}, errors.Errorf("%v\n%s", err, string(dump))
-	}
-	defer resp.Body.Close()
-	if s := resp.StatusCode; s < 200 || s >= 300 {
-		return VOD{}, errors.Errorf("invalid status code %d\n%s", s, string(dump))
-	}
-
+	query := `query($id: ID!) {
+		video(id: $id) {` + videoFields + `}
+	}`
 	type respPayload struct {
 		Data struct {
-			Video struct {
-				Title string `json:"title"`
-			} `json:"video"`
+			Video *videoPayload `json:"video"`
 		} `json:"data"`
 	}
 	var p respPayload
-	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
-		return VOD{}, errors.Errorf("%v\n%s", err, string(dump))
+	if err := c.gql(ctx, query, map[string]interface{}{"id": id}, &p); err != nil {
+		return VOD{}, err
+	}
+	if p.Data.Video == nil {
+		return VOD{}, errors.Errorf("VOD %s not found", id)
 	}
-	return VOD{Title: p.Data.Video.Title}, nil
+	return p.Data.Video.vod(), nil
 }
 
 
+// ClipInfo describes a twitch Clip.
+type ClipInfo struct {
+	ID           string
+	Slug         string
+	Title        string
+	URL          string
+	ChannelLogin string
+	ChannelName  string
+	CuratorLogin string
+	CuratorName  string
+	CreatedAt    time.Time
+	Duration     time.Duration
+	ViewCount    int
+	Game         Game
+	// VideoID is the ID of the VOD the clip was taken from, if still available.
+	VideoID string
+	// VideoOffset is the position of the clip inside the VOD "VideoID".
+	VideoOffset time.Duration
+	Qualities   []Clip
+}
+
 // Clip retrieves the video informations of a specific Clip
-func (c *Client) Clip(ctx context.Context, id string) (VOD, error) {
-	query_body := `
-	{
-		clip(slug:"%s") {
+func (c *Client) Clip(ctx context.Context, id string) (ClipInfo, error) {
+	query := `query($slug: ID!) {
+		clip(slug: $slug) {
 			id
 			slug
 			title
@@ -266,6 +415,7 @@ func (c *Client) Clip(ctx context.Context, id string) (VOD, error) {
 			viewCount
 			durationSeconds
 			url
+			videoOffsetSeconds
 			videoQualities {
 				frameRate
 				quality
@@ -279,106 +429,91 @@ func (c *Client) Clip(ctx context.Context, id string) (VOD, error) {
 				displayName
 				login
 			}
+			curator {
+				displayName
+				login
+			}
+			video {
+				id
+			}
 		}
-	}
-`
-	query_body = fmt.Sprintf(query_body, id)
-	type Query struct {
-		Query string
-	}
-
-	m := Query{query_body}
-	b, err := json.Marshal(m)
-	if err != nil {
-		return VOD{}, errors.WithStack(err)
-	}
-	body := strings.NewReader(string(b))
-
-	req, err := http.NewRequest(http.MethodPost, c.apiURL, body)
-	if err != nil {
-		return VOD{}, errors.WithStack(err)
-	}
-
-	dump, err := httputil.DumpRequestOut(req, true)
-	if err != nil {
-		return VOD{}, errors.WithStack(err)
-	}
-
-	req.Header.Set("Client-Id", c.clientID)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return VOD{}, errors.Errorf("%v\n%s", err, string(dump))
-	}
-	defer resp.Body.Close()
-	if s := resp.StatusCode; s < 200 || s >= 300 {
-		return VOD{}, errors.Errorf("invalid status code %d\n%s", s, string(dump))
+	}`
+	type user struct {
+		Login       string `json:"login"`
+		DisplayName string `json:"displayName"`
 	}
 	type respPayload struct {
 		Data struct {
-			Clip struct {
-				Title string `json:"title"`
+			Clip *struct {
+				ID                 string    `json:"id"`
+				Slug               string    `json:"slug"`
+				Title              string    `json:"title"`
+				CreatedAt          time.Time `json:"createdAt"`
+				ViewCount          int       `json:"viewCount"`
+				DurationSeconds    float64   `json:"durationSeconds"`
+				URL                string    `json:"url"`
+				VideoOffsetSeconds int       `json:"videoOffsetSeconds"`
+				VideoQualities     []struct {
+					Quality   string  `json:"quality"`
+					FrameRate float64 `json:"frameRate"`
+					SourceURL string  `json:"sourceURL"`
+				} `json:"videoQualities"`
+				Game *struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"game"`
+				Broadcaster *user `json:"broadcaster"`
+				Curator     *user `json:"curator"`
+				Video       *struct {
+					ID string `json:"id"`
+				} `json:"video"`
 			} `json:"clip"`
 		} `json:"data"`
 	}
 	var p respPayload
-	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
-		return VOD{}, errors.Errorf("%v\n%s", err, string(dump))
+	if err := c.gql(ctx, query, map[string]interface{}{"slug": id}, &p); err != nil {
+		return ClipInfo{}, err
 	}
-	return VOD{Title: p.Data.Clip.Title}, nil
-}
-
-
-// Clip_url retrieves the clip url info (Quality, FrameRate, Quality_option, SourceUrl ) of a specific Clip
-func (c *Client) Clip_url(ctx context.Context, id string) ([]Clip, error) {
-	var list []Clip
-
-	gqlPayload :=  `{"operationName":"VideoAccessToken_Clip","query":"title","variables":{"slug":"%s"},"extensions":{"persistedQuery":{"version":1,"sha256Hash":"36b89d2507fce29e5ca551df756d27c1cfe079e2609642b4390aa4c35796eb11"}}}`
-	body := strings.NewReader(fmt.Sprintf(gqlPayload, id))
-
-	req, err := http.NewRequest(http.MethodPost, c.apiURL, body)
-	if err != nil {
-		return list, errors.WithStack(err)
+	clip := p.Data.Clip
+	if clip == nil {
+		return ClipInfo{}, errors.Errorf("clip %s not found", id)
 	}
-
-	dump, err := httputil.DumpRequestOut(req, true)
-
-	if err != nil {
-		return list, errors.WithStack(err)
+	info := ClipInfo{
+		ID:        clip.ID,
+		Slug:      clip.Slug,
+		Title:     clip.Title,
+		URL:       clip.URL,
+		CreatedAt: clip.CreatedAt,
+		Duration:  time.Duration(clip.DurationSeconds * float64(time.Second)),
+		ViewCount: clip.ViewCount,
 	}
-
-	req.Header.Set("Client-Id", c.clientID)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return list, errors.Errorf("%v\n%s", err, string(dump))
+	if clip.Game != nil {
+		info.Game = Game{ID: clip.Game.ID, Name: clip.Game.Name}
 	}
-
-	defer resp.Body.Close()
-	if s := resp.StatusCode; s < 200 || s >= 300 {
-		return list, errors.Errorf("invalid status code %d\n%s", s, string(dump))
+	if clip.Broadcaster != nil {
+		info.ChannelLogin, info.ChannelName = clip.Broadcaster.Login, clip.Broadcaster.DisplayName
 	}
-
-	type respPayload struct {
-		Data struct {
-			Clip struct {
-				VideoQualities []struct{
-					Quality string `json:"quality"`
-					FrameRate int `json:"frameRate"`
-					SourceURL string `json:"sourceURL"`
-				} `json:"videoQualities"`
-			} `json:"clip"`
-		} `json:"data"`
+	if clip.Curator != nil {
+		info.CuratorLogin, info.CuratorName = clip.Curator.Login, clip.Curator.DisplayName
 	}
-
-	var p respPayload
-	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
-		return list, errors.Errorf("%v\n%s", err, string(dump))
+	if clip.Video != nil {
+		info.VideoID = clip.Video.ID
+		info.VideoOffset = time.Duration(clip.VideoOffsetSeconds) * time.Second
 	}
-
-	for _,v:= range p.Data.Clip.VideoQualities{
-		list = append(list, Clip{v.Quality, v.FrameRate, fmt.Sprintf("%sp%d", v.Quality, v.FrameRate), v.SourceURL})
+	for _, v := range clip.VideoQualities {
+		frameRate := int(math.Round(v.FrameRate))
+		info.Qualities = append(info.Qualities, Clip{v.Quality, frameRate, fmt.Sprintf("%sp%d", v.Quality, frameRate), v.SourceURL})
 	}
+	return info, nil
+}
 
-	return list, nil
+
+// Clip_url retrieves the clip url info (Quality, FrameRate, Quality_option, SourceUrl ) of a specific Clip.
+// They are the Qualities returned by Clip.
+func (c *Client) Clip_url(ctx context.Context, id string) ([]Clip, error) {
+	info, err := c.Clip(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return info.Qualities, nil
 }
@@ -1,9 +1,16 @@
 package twitch_test
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/jybp/twitch-downloader/twitch"
 )
@@ -34,3 +41,349 @@ func TestID_Stream(t *testing.T) {
 	_, err := twitch.ID("https://www.twitch.tv/test")
 	assert.NotNil(t, err)
 }
+
+type countingTransport struct {
+	count int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_Middlewares(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "id", r.Header.Get("Client-Id"))
+		assert.Equal(t, "value", r.Header.Get("X-Custom"))
+		w.Write([]byte(`{"data":{"video":{"title":"title"}}}`))
+	}))
+	defer srv.Close()
+
+	transport := &countingTransport{}
+	var order []string
+	trace := func(name string) twitch.Middleware {
+		return func(next twitch.Doer) twitch.Doer {
+			return twitch.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.Do(req)
+			})
+		}
+	}
+	api := twitch.Custom(&http.Client{Transport: transport}, "id", srv.URL, srv.URL+"/",
+		trace("first"), twitch.Header("X-Custom", "value"), trace("second"))
+	vod, err := api.VOD(context.Background(), "12345")
+	require.NoError(t, err)
+	assert.Equal(t, "title", vod.Title)
+	assert.Equal(t, 1, transport.count)
+	assert.Equal(t, []string{"first", "second"}, order)
+}
+
+func TestRateLimit(t *testing.T) {
+	doer := twitch.RateLimit(20 * time.Millisecond)(twitch.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}))
+	from := time.Now()
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		_, err = doer.Do(req)
+		require.NoError(t, err)
+	}
+	assert.True(t, time.Since(from) >= 40*time.Millisecond)
+}
+
+func TestLiveM3U8(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gql":
+			w.Write([]byte(`{"data":{"streamPlaybackAccessToken":{"value":"{\"channel\":\"name\"}","signature":"sig"}}}`))
+		case "/api/channel/hls/name.m3u8":
+			assert.Equal(t, `{"channel":"name"}`, r.URL.Query().Get("token"))
+			assert.Equal(t, "sig", r.URL.Query().Get("sig"))
+			w.Write([]byte("#EXTM3U"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL+"/gql", srv.URL+"/")
+	b, err := api.LiveM3U8(context.Background(), "Name")
+	require.NoError(t, err)
+	assert.Equal(t, "#EXTM3U", string(b))
+
+	_, err = api.LiveM3U8(context.Background(), "offline")
+	assert.Equal(t, twitch.ErrOffline, errors.Cause(err))
+}
+
+func TestLiveM3U8_Escaped(t *testing.T) {
+	login := `na"me\`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Variables struct {
+				IsLive bool   `json:"isLive"`
+				Login  string `json:"login"`
+			} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.True(t, payload.Variables.IsLive)
+		assert.Equal(t, login, payload.Variables.Login)
+		w.Write([]byte(`{"data":{"streamPlaybackAccessToken":{"value":"","signature":""}}}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	_, err := api.LiveM3U8(context.Background(), login)
+	assert.Equal(t, twitch.ErrOffline, errors.Cause(err))
+}
+
+func TestVideos(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Variables map[string]string `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "name", payload.Variables["login"])
+		assert.Equal(t, "ARCHIVE", payload.Variables["type"])
+		if payload.Variables["after"] == "" {
+			w.Write([]byte(`{"data":{"user":{"videos":{"edges":[
+				{"cursor":"c1","node":{"id":"2","title":"second","broadcastType":"ARCHIVE","createdAt":"2020-01-02T15:04:05Z","lengthSeconds":3600,"viewCount":10,"game":{"id":"1","name":"game"},"owner":{"login":"name","displayName":"Name"}}}
+			],"pageInfo":{"hasNextPage":true}}}}}`))
+			return
+		}
+		assert.Equal(t, "c1", payload.Variables["after"])
+		w.Write([]byte(`{"data":{"user":{"videos":{"edges":[
+			{"cursor":"c2","node":{"id":"1","title":"first","broadcastType":"ARCHIVE","createdAt":"2020-01-01T15:04:05Z","lengthSeconds":60,"viewCount":1,"game":null,"owner":{"login":"name","displayName":"Name"}}}
+		],"pageInfo":{"hasNextPage":false}}}}}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	vods, next, err := api.Videos(context.Background(), "Name", twitch.Archive, "")
+	require.NoError(t, err)
+	require.Len(t, vods, 1)
+	assert.Equal(t, "c1", next)
+	assert.Equal(t, twitch.VOD{
+		ID:            "2",
+		Title:         "second",
+		ChannelLogin:  "name",
+		ChannelName:   "Name",
+		BroadcastType: twitch.Archive,
+		CreatedAt:     time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC),
+		Length:        time.Hour,
+		ViewCount:     10,
+		Game:          twitch.Game{ID: "1", Name: "game"},
+	}, vods[0])
+
+	vods, next, err = api.Videos(context.Background(), "Name", twitch.Archive, next)
+	require.NoError(t, err)
+	require.Len(t, vods, 1)
+	assert.Equal(t, "", next)
+	assert.Equal(t, "1", vods[0].ID)
+	assert.Equal(t, "", vods[0].Game.Name)
+}
+
+func TestVOD(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Variables map[string]string `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		if payload.Variables["id"] != "12345" {
+			w.Write([]byte(`{"data":{"video":null}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"video":{
+			"id":"12345",
+			"title":"title",
+			"description":"description",
+			"broadcastType":"ARCHIVE",
+			"createdAt":"2020-01-02T15:04:05Z",
+			"publishedAt":"2020-01-02T15:04:06Z",
+			"lengthSeconds":7200,
+			"viewCount":42,
+			"game":{"id":"1","name":"game"},
+			"owner":{"login":"name","displayName":"Name"},
+			"previewThumbnailURL":"https://example.com/preview.jpg",
+			"thumbnailURLs":["https://example.com/0.jpg","https://example.com/1.jpg"],
+			"muteInfo":{"mutedSegmentConnection":{"nodes":[{"offset":360,"duration":180}]}}
+		}}}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	vod, err := api.VOD(context.Background(), "12345")
+	require.NoError(t, err)
+	assert.Equal(t, twitch.VOD{
+		ID:            "12345",
+		Title:         "title",
+		Description:   "description",
+		ChannelLogin:  "name",
+		ChannelName:   "Name",
+		BroadcastType: twitch.Archive,
+		CreatedAt:     time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC),
+		PublishedAt:   time.Date(2020, 1, 2, 15, 4, 6, 0, time.UTC),
+		Length:        2 * time.Hour,
+		ViewCount:     42,
+		Game:          twitch.Game{ID: "1", Name: "game"},
+		PreviewURL:    "https://example.com/preview.jpg",
+		ThumbnailURLs: []string{"https://example.com/0.jpg", "https://example.com/1.jpg"},
+		MutedSegments: []twitch.MutedSegment{{Offset: 6 * time.Minute, Duration: 3 * time.Minute}},
+	}, vod)
+
+	_, err = api.VOD(context.Background(), "0")
+	assert.Error(t, err)
+}
+
+func TestClip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"clip":{
+			"id":"1",
+			"slug":"Slug",
+			"title":"title",
+			"createdAt":"2020-01-02T15:04:05Z",
+			"viewCount":42,
+			"durationSeconds":29.5,
+			"url":"https://clips.twitch.tv/Slug",
+			"videoOffsetSeconds":120,
+			"videoQualities":[{"frameRate":59.94,"quality":"1080","sourceURL":"https://example.com/1080.mp4"}],
+			"game":{"id":"2","name":"game"},
+			"broadcaster":{"login":"name","displayName":"Name"},
+			"curator":{"login":"curator","displayName":"Curator"},
+			"video":{"id":"12345"}
+		}}}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	clip, err := api.Clip(context.Background(), "Slug")
+	require.NoError(t, err)
+	assert.Equal(t, twitch.ClipInfo{
+		ID:           "1",
+		Slug:         "Slug",
+		Title:        "title",
+		URL:          "https://clips.twitch.tv/Slug",
+		ChannelLogin: "name",
+		ChannelName:  "Name",
+		CuratorLogin: "curator",
+		CuratorName:  "Curator",
+		CreatedAt:    time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC),
+		Duration:     29*time.Second + 500*time.Millisecond,
+		ViewCount:    42,
+		Game:         twitch.Game{ID: "2", Name: "game"},
+		VideoID:      "12345",
+		VideoOffset:  2 * time.Minute,
+		Qualities: []twitch.Clip{
+			{Quality: "1080", FrameRate: 60, Quality_option: "1080p60", SourceURL: "https://example.com/1080.mp4"},
+		},
+	}, clip)
+}
+
+func TestClipToken(t *testing.T) {
+	slug := `Sl"ug\`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Variables struct {
+				Slug string `json:"slug"`
+			} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		if payload.Variables.Slug != slug {
+			// A clip that does not exist.
+			w.Write([]byte(`{"data":{"clip":null}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"clip":{"playbackAccessToken":{"value":"{\"authorization\":{}}","signature":"sig"}}}}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	token, sig, err := api.ClipToken(context.Background(), slug)
+	require.NoError(t, err)
+	assert.Equal(t, "%7B%22authorization%22%3A%7B%7D%7D", token)
+	assert.Equal(t, "sig", sig)
+
+	_, _, err = api.ClipToken(context.Background(), "missing")
+	assert.Error(t, err)
+}
+
+func TestOAuthToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gql":
+			if r.Header.Get("Authorization") == "OAuth expired" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"Unauthorized","status":401,"message":"The \"Authorization\" token is invalid."}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"videoPlaybackAccessToken":{"value":"token","signature":"sig"}}}`))
+		case "/vod/12345":
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("Authorization sent to usher")
+			}
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`[{"type":"error","error":"Unauthorized","error_code":"vod_manifest_restricted","url":"https://www.twitch.tv/videos/12345"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL+"/gql", srv.URL+"/")
+	_, err := api.M3U8(context.Background(), "12345")
+	denied, ok := errors.Cause(err).(*twitch.AccessDeniedError)
+	require.True(t, ok, "%v", err)
+	assert.Equal(t, "vod_manifest_restricted", denied.Code)
+	assert.False(t, denied.Authenticated)
+	assert.Contains(t, err.Error(), "requires the OAuth token")
+
+	authenticated := api.WithOAuthToken("oauth:secret")
+	_, err = authenticated.M3U8(context.Background(), "12345")
+	denied, ok = errors.Cause(err).(*twitch.AccessDeniedError)
+	require.True(t, ok, "%v", err)
+	assert.True(t, denied.Authenticated)
+
+	expired := api.WithOAuthToken("expired")
+	_, err = expired.M3U8(context.Background(), "12345")
+	denied, ok = errors.Cause(err).(*twitch.AccessDeniedError)
+	require.True(t, ok, "%v", err)
+	assert.Equal(t, "invalid_token", denied.Code)
+	assert.NotContains(t, err.Error(), "OAuth expired")
+}
+
+func TestOAuthToken_Clip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"Forbidden","status":403,"message":"clip is restricted"}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	_, err := api.Clip_url(context.Background(), "slug")
+	denied, ok := errors.Cause(err).(*twitch.AccessDeniedError)
+	require.True(t, ok, "%v", err)
+	assert.Equal(t, "clip is restricted", denied.Message)
+
+	authenticated := api.WithOAuthToken("secret")
+	_, _, err = authenticated.ClipToken(context.Background(), "slug")
+	denied, ok = errors.Cause(err).(*twitch.AccessDeniedError)
+	require.True(t, ok, "%v", err)
+	assert.True(t, denied.Authenticated)
+}
+
+func TestOAuthToken_Header(t *testing.T) {
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"data":{"video":{"id":"12345","title":"title"}}}`))
+	}))
+	defer srv.Close()
+
+	api := twitch.Custom(srv.Client(), "id", srv.URL, srv.URL+"/")
+	_, err := api.VOD(context.Background(), "12345")
+	require.NoError(t, err)
+	authenticated := api.WithOAuthToken("OAuth secret")
+	_, err = authenticated.VOD(context.Background(), "12345")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "OAuth secret"}, auth)
+}
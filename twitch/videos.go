@@ -0,0 +1,206 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Broadcast types accepted by Client.Videos.
+const (
+	Archive   = "ARCHIVE"
+	Highlight = "HIGHLIGHT"
+	Upload    = "UPLOAD"
+)
+
+// gql sends the GraphQL query with its variables and decodes the response into v.
+func (c *Client) gql(ctx context.Context, query string, variables map[string]interface{}, v interface{}) error {
+	b, err := json.Marshal(struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables,omitempty"`
+	}{query, variables})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.apiURL, bytes.NewReader(b))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Client-Id", c.clientID)
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.authorize(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Errorf("%v\n%s", err, string(dump))
+	}
+	defer resp.Body.Close()
+	if s := resp.StatusCode; s < 200 || s >= 300 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		if err := c.accessDenied(s, b); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.Errorf("invalid status code %d\n%s", s, string(dump))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Errorf("%v\n%s", err, string(dump))
+	}
+	return nil
+}
+
+// videoFields are the fields of a video queried to fill a VOD.
+const videoFields = `
+	id
+	title
+	description
+	broadcastType
+	createdAt
+	publishedAt
+	lengthSeconds
+	viewCount
+	game {
+		id
+		name
+	}
+	owner {
+		login
+		displayName
+	}
+	previewThumbnailURL(width: 1920, height: 1080)
+	thumbnailURLs(width: 320, height: 180)
+	muteInfo {
+		mutedSegmentConnection {
+			nodes {
+				offset
+				duration
+			}
+		}
+	}
+`
+
+type videoPayload struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	BroadcastType string    `json:"broadcastType"`
+	CreatedAt     time.Time `json:"createdAt"`
+	PublishedAt   time.Time `json:"publishedAt"`
+	LengthSeconds int       `json:"lengthSeconds"`
+	ViewCount     int       `json:"viewCount"`
+	Game          *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"game"`
+	Owner struct {
+		Login       string `json:"login"`
+		DisplayName string `json:"displayName"`
+	} `json:"owner"`
+	PreviewThumbnailURL string   `json:"previewThumbnailURL"`
+	ThumbnailURLs       []string `json:"thumbnailURLs"`
+	MuteInfo            *struct {
+		MutedSegmentConnection *struct {
+			Nodes []struct {
+				Offset   int `json:"offset"`
+				Duration int `json:"duration"`
+			} `json:"nodes"`
+		} `json:"mutedSegmentConnection"`
+	} `json:"muteInfo"`
+}
+
+func (p videoPayload) vod() VOD {
+	vod := VOD{
+		ID:            p.ID,
+		Title:         p.Title,
+		Description:   p.Description,
+		ChannelLogin:  p.Owner.Login,
+		ChannelName:   p.Owner.DisplayName,
+		BroadcastType: strings.ToUpper(p.BroadcastType),
+		CreatedAt:     p.CreatedAt,
+		PublishedAt:   p.PublishedAt,
+		Length:        time.Duration(p.LengthSeconds) * time.Second,
+		ViewCount:     p.ViewCount,
+		PreviewURL:    p.PreviewThumbnailURL,
+		ThumbnailURLs: p.ThumbnailURLs,
+	}
+	if p.Game != nil {
+		vod.Game = Game{ID: p.Game.ID, Name: p.Game.Name}
+	}
+	if p.MuteInfo != nil && p.MuteInfo.MutedSegmentConnection != nil {
+		for _, node := range p.MuteInfo.MutedSegmentConnection.Nodes {
+			vod.MutedSegments = append(vod.MutedSegments, MutedSegment{
+				Offset:   time.Duration(node.Offset) * time.Second,
+				Duration: time.Duration(node.Duration) * time.Second,
+			})
+		}
+	}
+	return vod
+}
+
+// Videos retrieves a page of the VODs of "channel" sorted from the most recent.
+// broadcastType is one of Archive, Highlight or Upload; all types are returned if empty.
+// cursor is empty for the first page. next is the cursor of the following page
+// and is empty on the last page.
+func (c *Client) Videos(ctx context.Context, channel, broadcastType, cursor string) (vods []VOD, next string, err error) {
+	query := `query($login: String!, $type: BroadcastType, $after: Cursor) {
+		user(login: $login) {
+			videos(first: 100, after: $after, type: $type, sort: TIME) {
+				edges {
+					cursor
+					node {` + videoFields + `}
+				}
+				pageInfo {
+					hasNextPage
+				}
+			}
+		}
+	}`
+	variables := map[string]interface{}{"login": strings.ToLower(channel)}
+	if len(broadcastType) > 0 {
+		variables["type"] = strings.ToUpper(broadcastType)
+	}
+	if len(cursor) > 0 {
+		variables["after"] = cursor
+	}
+
+	type respPayload struct {
+		Data struct {
+			User *struct {
+				Videos struct {
+					Edges []struct {
+						Cursor string       `json:"cursor"`
+						Node   videoPayload `json:"node"`
+					} `json:"edges"`
+					PageInfo struct {
+						HasNextPage bool `json:"hasNextPage"`
+					} `json:"pageInfo"`
+				} `json:"videos"`
+			} `json:"user"`
+		} `json:"data"`
+	}
+	var p respPayload
+	if err := c.gql(ctx, query, variables, &p); err != nil {
+		return nil, "", err
+	}
+	if p.Data.User == nil {
+		return nil, "", errors.Errorf("channel %s not found", channel)
+	}
+	videos := p.Data.User.Videos
+	for _, edge := range videos.Edges {
+		vods = append(vods, edge.Node.vod())
+		next = edge.Cursor
+	}
+	if !videos.PageInfo.HasNextPage {
+		next = ""
+	}
+	return vods, next, nil
+}